@@ -3,15 +3,44 @@ package config
 import (
 	"github.com/apex/log"
 	"github.com/caarlos0/env/v6"
+	"time"
 )
 
 // Config configuration.
 type Config struct {
-	RedisUrl              string   `env:"REDIS_URL" envDefault:"redis://localhost:6379"`
-	GithubTokens          []string `env:"GITHUB_TOKENS"`
-	GithubPageSize        int      `env:"GITHUB_PAGE_SIZE" envDefault:"100"`
-	GitHubMaxRateUsagePct int      `env:"GITHUB_MAX_RATE_LIMIT_USAGE" envDefault:"80"`
-	Listen                string   `env:"LISTEN" envDefault:"127.0.0.1:3000"`
+	RedisUrl                      string        `env:"REDIS_URL" envDefault:"redis://localhost:6379"`
+	CacheBackend                  string        `env:"CACHE_BACKEND" envDefault:"redis"`
+	CacheMemoryMaxItems           int           `env:"CACHE_MEMORY_MAX_ITEMS" envDefault:"10000"`
+	CacheMemoryMaxBytes           int64         `env:"CACHE_MEMORY_MAX_BYTES" envDefault:"268435456"`
+	CacheMemoryTTL                time.Duration `env:"CACHE_MEMORY_TTL" envDefault:"24h"`
+	CacheChartTTL                 time.Duration `env:"CACHE_CHART_TTL" envDefault:"24h"`
+	CacheChartFreshness           time.Duration `env:"CACHE_CHART_FRESHNESS" envDefault:"1h"`
+	CacheRepoTTL                  time.Duration `env:"CACHE_REPO_TTL" envDefault:"24h"`
+	CacheStargazersTTL            time.Duration `env:"CACHE_STARGAZERS_TTL" envDefault:"720h"`
+	StorePath                     string        `env:"STORE_PATH" envDefault:"data"`
+	StoreMaxRepos                 int           `env:"STORE_MAX_REPOS" envDefault:"100"`
+	SnapshotInterval              time.Duration `env:"SNAPSHOT_INTERVAL" envDefault:"1h"`
+	GithubTokens                  []string      `env:"GITHUB_TOKENS"`
+	GithubTokenRevalidateInterval time.Duration `env:"GITHUB_TOKEN_REVALIDATE_INTERVAL" envDefault:"15m"`
+	GithubApiUrl                  string        `env:"GITHUB_API_URL" envDefault:"https://api.github.com"`
+	GithubGraphqlUrl              string        `env:"GITHUB_GRAPHQL_URL"`
+	GithubTimeout                 time.Duration `env:"GITHUB_TIMEOUT" envDefault:"15s"`
+	GithubMaxRetries              int           `env:"GITHUB_MAX_RETRIES" envDefault:"3"`
+	GithubMaxRetryAfter           time.Duration `env:"GITHUB_MAX_RETRY_AFTER" envDefault:"1m"`
+	GithubPageSize                int           `env:"GITHUB_PAGE_SIZE" envDefault:"100"`
+	GithubStargazersFetcher       string        `env:"GITHUB_STARGAZERS_FETCHER" envDefault:"rest"`
+	GithubFetchLockTTL            time.Duration `env:"GITHUB_FETCH_LOCK_TTL" envDefault:"30s"`
+	GithubBackgroundRateUsagePct  int           `env:"GITHUB_BACKGROUND_MAX_RATE_LIMIT_USAGE" envDefault:"50"`
+	GitHubMaxRateUsagePct         int           `env:"GITHUB_MAX_RATE_LIMIT_USAGE" envDefault:"80"`
+	ChartAsyncMinPages            int           `env:"CHART_ASYNC_MIN_PAGES" envDefault:"50"`
+	JobsWorkers                   int           `env:"JOBS_WORKERS" envDefault:"2"`
+	JobsQueueSize                 int           `env:"JOBS_QUEUE_SIZE" envDefault:"100"`
+	JobsTimeout                   time.Duration `env:"JOBS_TIMEOUT" envDefault:"15m"`
+	JobsStatusTTL                 time.Duration `env:"JOBS_STATUS_TTL" envDefault:"1h"`
+	TrackedReposFile              string        `env:"TRACKED_REPOS_FILE" envDefault:"tracked_repos.txt"`
+	TrackedRefreshInterval        time.Duration `env:"TRACKED_REFRESH_INTERVAL" envDefault:"1h"`
+	AdminToken                    string        `env:"ADMIN_TOKEN"`
+	Listen                        string        `env:"LISTEN" envDefault:"127.0.0.1:3000"`
 }
 
 // Get the current Config.
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"context"
 	"fmt"
 	"github.com/apex/log"
 	"github.com/caarlos0/httperr"
@@ -10,6 +11,9 @@ import (
 	"strarcharts/internal/chart"
 	"strarcharts/internal/chart/svg"
 	"strarcharts/internal/github"
+	"strarcharts/internal/jobs"
+	"strarcharts/internal/store"
+	"strarcharts/internal/timeline"
 	"strings"
 	"time"
 )
@@ -20,7 +24,74 @@ var stylesMap = map[string]string{
 	"adaptive": chart.AdaptiveStyles,
 }
 
-func GetRepoChart(gh *github.GitHub, cache *cache.Redis) http.Handler {
+var themesMap = map[string]chart.Theme{
+	"light":    chart.LightTheme,
+	"dark":     chart.DarkTheme,
+	"adaptive": chart.LightTheme,
+}
+
+// chartFormat is an image format charts can be rendered to.
+type chartFormat struct {
+	extension     string
+	contentType   string
+	render        func(graph *chart.Chart, w io.Writer) error
+	renderError   func(w http.ResponseWriter, err error) error
+	renderPending func(w http.ResponseWriter, placeholder *chart.Placeholder) error
+}
+
+var svgFormat = chartFormat{
+	extension:   "svg",
+	contentType: "image/svg+xml;charset=utf-8",
+	render: func(graph *chart.Chart, w io.Writer) error {
+		graph.Render(w)
+		return nil
+	},
+	renderError: func(w http.ResponseWriter, err error) error {
+		writeSvgHeaders(w, 0, 0, 0)
+		_, err = w.Write([]byte(errSvg(err)))
+		return err
+	},
+	renderPending: func(w http.ResponseWriter, placeholder *chart.Placeholder) error {
+		writeSvgHeaders(w, 0, 0, 0)
+		w.Header().Set("cache-control", "no-cache")
+		placeholder.Render(w)
+		return nil
+	},
+}
+
+var pngFormat = chartFormat{
+	extension:   "png",
+	contentType: "image/png",
+	render: func(graph *chart.Chart, w io.Writer) error {
+		return graph.RenderPNG(w)
+	},
+	renderError: func(w http.ResponseWriter, err error) error {
+		return httperr.Wrap(err, http.StatusBadGateway)
+	},
+	renderPending: func(w http.ResponseWriter, placeholder *chart.Placeholder) error {
+		writeImageHeaders(w, "image/png", 0, 0, 0)
+		w.Header().Set("cache-control", "no-cache")
+		return placeholder.RenderPNG(w)
+	},
+}
+
+// GetRepoChart renders the star history of a repository, caching the result
+// for ttl and rebuilding it in the background once older than freshness.
+// Repositories needing at least asyncPages stargazer pages are built by a
+// queued job instead, a placeholder with the progress is rendered meanwhile.
+// Charts the job could only build incompletely are served as they are for a
+// while, instead of queuing the job again right away.
+func GetRepoChart(gh *github.GitHub, cache cache.Cache, history *store.Store, queue *jobs.Queue, ttl, freshness time.Duration, asyncPages int) http.Handler {
+	return getRepoChart(gh, cache, history, queue, ttl, freshness, asyncPages, svgFormat)
+}
+
+// GetRepoChartPNG is the same as GetRepoChart, rendering a PNG instead.
+func GetRepoChartPNG(gh *github.GitHub, cache cache.Cache, history *store.Store, queue *jobs.Queue, ttl, freshness time.Duration, asyncPages int) http.Handler {
+	return getRepoChart(gh, cache, history, queue, ttl, freshness, asyncPages, pngFormat)
+}
+
+func getRepoChart(gh *github.GitHub, cache cache.Cache, history *store.Store, queue *jobs.Queue, ttl, freshness time.Duration, asyncPages int, format chartFormat) http.Handler {
+	charts := chartCache{cache: cache, ttl: ttl, freshness: freshness}
 	return httperr.NewF(func(w http.ResponseWriter, r *http.Request) error {
 		params, err := extractSvgChartParams(r)
 		if err != nil {
@@ -28,76 +99,275 @@ func GetRepoChart(gh *github.GitHub, cache *cache.Redis) http.Handler {
 			return err
 		}
 
-		cacheKey := chartKey(params)
+		cacheKey := chartKey(params) + "." + format.extension
 		name := fmt.Sprintf("%s/%s", params.Owner, params.Repo)
 		log := log.WithField("repo", name).WithField("variant", params.Variant)
+		rebuild := func(ctx context.Context) (builtChart, error) {
+			repo, err := gh.RepoDetails(ctx, name)
+			if err != nil {
+				return builtChart{}, err
+			}
+			return buildRepoChart(ctx, gh, history, repo, params, format)
+		}
 
-		cacheChart := ""
-		if err = cache.Get(cacheKey, &cacheChart); err == nil {
-			writeSvgHeaders(w)
-			log.Debug("using cached chart")
-			_, err := fmt.Fprint(w, cacheChart)
+		if ok, err := charts.serve(w, cacheKey, format.contentType, rebuild); ok {
 			return err
 		}
 
-		defer log.Trace("collect_stars").Stop(nil)
 		repo, err := gh.RepoDetails(r.Context(), name)
-
 		if err != nil {
 			return httperr.Wrap(err, http.StatusBadRequest)
 		}
-		stargazers, err := gh.Stargazers(r.Context(), repo)
+		build := func(ctx context.Context) (builtChart, error) {
+			return buildRepoChart(ctx, gh, history, repo, params, format)
+		}
+		// snapshots are already stored, only stargazers take long to get.
+		if pages := gh.PendingPages(repo); params.Source != "snapshots" && pages >= asyncPages {
+			if ok, err := charts.serveIncomplete(w, cacheKey, format.contentType); ok {
+				return err
+			}
+			err := queueBuild(queue, repo.FullName, cacheKey, func(ctx context.Context) error {
+				_, err := charts.build(ctx, cacheKey, build)
+				return err
+			})
+			if err == nil {
+				log.WithField("pages", pages).Info("building chart in the background")
+				return format.renderPending(w, pendingPlaceholder(gh, params, repo.FullName))
+			}
+			log.WithError(err).Warn("failed to queue chart build, building it now")
+		}
+
+		built, err := charts.build(r.Context(), cacheKey, build)
 		if err != nil {
 			log.WithError(err).Error("failed to get stars")
-			writeSvgHeaders(w)
-			_, err = w.Write([]byte(errSvg(err)))
-			return err
+			return format.renderError(w, err)
 		}
+		return charts.write(w, format.contentType, built)
+	})
+}
 
-		series := chart.Series{
-			StrokeWidth: 2,
-			Color:       params.Line,
+// buildRepoChart renders the star history of the repository.
+func buildRepoChart(ctx context.Context, gh *github.GitHub, history *store.Store, repo github.Repository, params *params, format chartFormat) (builtChart, error) {
+	log := log.WithField("repo", repo.FullName).WithField("variant", params.Variant)
+	defer log.Trace("collect_stars").Stop(nil)
+
+	graph, complete, err := repoChart(ctx, gh, history, repo, params)
+	if err != nil {
+		return builtChart{}, err
+	}
+	if params.Annotations == "releases" {
+		graph.Annotations = releaseAnnotations(ctx, gh, repo.FullName)
+	}
+	defer log.Trace("chart").Stop(&err)
+
+	body := &strings.Builder{}
+	if err = format.render(graph, body); err != nil {
+		return builtChart{}, err
+	}
+	return builtChart{body: body.String(), complete: complete}, nil
+}
+
+// repoChart builds the chart of the repository from the source asked for. It
+// also tells whether the chart is complete, like starsChart.
+func repoChart(ctx context.Context, gh *github.GitHub, history *store.Store, repo github.Repository, params *params) (*chart.Chart, bool, error) {
+	switch params.Source {
+	case "snapshots":
+		snapshots, err := snapshotPoints(history, repo)
+		if err != nil {
+			return nil, false, err
+		}
+		return pointsChart(snapshots, params), true, nil
+	case "merged":
+		snapshots, err := snapshotPoints(history, repo)
+		if err != nil {
+			return nil, false, err
+		}
+		stars, err := fetchStars(ctx, gh, history, repo)
+		if err != nil {
+			return nil, false, err
 		}
-		for i, star := range stargazers {
-			series.XValues = append(series.XValues, star.StarredAt)
-			series.YValues = append(series.YValues, float64(i+1))
+		graph, complete := mergedChart(stars, snapshots, params)
+		return graph, complete, nil
+	default:
+		stars, err := fetchStars(ctx, gh, history, repo)
+		if err != nil {
+			return nil, false, err
 		}
+		graph, complete := starsChart(stars, params)
+		return graph, complete, nil
+	}
+}
+
+// starsChart builds the chart of the repository stargazers. It also tells
+// whether the chart is complete, incomplete charts shouldn't be cached.
+func starsChart(stars repoStars, params *params) (*chart.Chart, bool) {
+	log := log.WithField("repo", stars.repo.FullName)
+	if stars.estimate != nil {
+		var graph *chart.Chart
+		if params.Type == "bars" {
+			graph = newChart(params, spreadBarsSeries(stars.estimate.Points, params.Bucket, params.Line))
+			graph.YAxis.Name = fmt.Sprintf("Stars per %s", params.Bucket)
+		} else {
+			graph = newChart(params, estimatedSeries(stars.estimate.Points, params.Line))
+		}
+		graph.Notice = estimatedNotice
+		if !stars.complete() {
+			log.WithField("failed", stars.estimate.FailedSamples).Warn("rendering incomplete estimate")
+			graph.Notice = partialEstimateNotice(*stars.estimate)
+		}
+		return graph, stars.complete()
+	}
+
+	var graph *chart.Chart
+	if params.Type == "bars" {
+		graph = newChart(params, barsSeries(stars.result.Stargazers, params.Bucket, params.Line))
+		graph.YAxis.Name = fmt.Sprintf("Stars per %s", params.Bucket)
+	} else {
+		series := starsSeries(stars.result.Stargazers, params.Line)
 		if len(series.XValues) < 2 {
 			log.Info("not enough results, adding some fake ones")
 			series.XValues = append(series.XValues, time.Now())
 			series.YValues = append(series.YValues, 1)
 		}
-
-		graph := &chart.Chart{
-			Width:      CHART_WIDTH,
-			Height:     CHART_HEIGHT,
-			Styles:     stylesMap[params.Variant],
-			Background: params.Background,
-			XAxis: chart.XAxis{
-				Name:        "Time",
-				Color:       params.Axis,
-				StrokeWidth: 2,
-			},
-			YAxis: chart.YAxis{
-				Name:        "Stargazers",
-				Color:       params.Axis,
-				StrokeWidth: 2,
-			},
-			Series: series,
+		graph = newChart(params, series)
+	}
+	if !stars.complete() {
+		log.WithField("failed", len(stars.result.FailedPages)).Warn("rendering incomplete chart")
+		graph.Notice = incompleteNotice(stars.result)
+		if stars.stored {
+			graph.Notice = storedNotice
 		}
-		defer log.Trace("chart").Stop(&err)
+	}
+	return graph, stars.complete()
+}
 
-		writeSvgHeaders(w)
+// estimatedNotice marks charts of repositories above the listing cap.
+const estimatedNotice = "Estimated: GitHub only lists the first 40k stargazers"
 
-		cacheBuffer := &strings.Builder{}
-		graph.Render(io.MultiWriter(w, cacheBuffer))
-		err = cache.Put(cacheKey, cacheBuffer.String())
-		if err != nil {
-			log.WithError(err).Error("failed to cache chart")
-		}
+// partialEstimateNotice tells how many sample pages are missing from the
+// estimate.
+func partialEstimateNotice(result github.EstimateResult) string {
+	return fmt.Sprintf("Estimated from partial data: %d of %d sample pages failed to load", result.FailedSamples, result.Samples)
+}
+
+// storedNotice marks charts drawn from the store alone as GitHub failed.
+const storedNotice = "Stored data: GitHub is unavailable, recent stars may be missing"
+
+// incompleteNotice tells how many pages are missing from the chart.
+func incompleteNotice(result github.StargazersResult) string {
+	return fmt.Sprintf("Incomplete data: %d of %d pages failed to load", len(result.FailedPages), result.Pages())
+}
+
+func starsSeries(stargazers []github.Stargazer, color string) chart.Series {
+	series := chart.Series{
+		StrokeWidth: 2,
+		Color:       color,
+	}
+	for i, star := range stargazers {
+		series.XValues = append(series.XValues, star.StarredAt)
+		series.YValues = append(series.YValues, float64(i+1))
+	}
+	return series
+}
+
+func starTimes(stargazers []github.Stargazer) []time.Time {
+	times := make([]time.Time, 0, len(stargazers))
+	for _, star := range stargazers {
+		times = append(times, star.StarredAt)
+	}
+	return times
+}
+
+// estimatedSeries draws the estimated cumulative stars as a dashed line.
+func estimatedSeries(points []timeline.Point, color string) chart.Series {
+	series := pointsSeries(points, color)
+	series.StrokeDashArray = chart.EstimateDashArray
+	return series
+}
+
+// pointsSeries draws cumulative stars as a line.
+func pointsSeries(points []timeline.Point, color string) chart.Series {
+	series := chart.Series{
+		StrokeWidth: 2,
+		Color:       color,
+	}
+	for _, point := range points {
+		series.XValues = append(series.XValues, point.Time)
+		series.YValues = append(series.YValues, float64(point.Count))
+	}
+	return series
+}
+
+// spreadBarsSeries spreads cumulative stars over buckets.
+func spreadBarsSeries(points []timeline.Point, bucket timeline.Bucket, color string) chart.Series {
+	series := chart.Series{
+		Type:  chart.BarSeries,
+		Color: color,
+	}
+	for _, point := range timeline.Spread(points, bucket) {
+		series.XValues = append(series.XValues, point.Time)
+		series.YValues = append(series.YValues, float64(point.Count))
+	}
+	return series
+}
+
+// barsSeries counts stargazers per bucket, rendered as bars.
+func barsSeries(stargazers []github.Stargazer, bucket timeline.Bucket, color string) chart.Series {
+	series := chart.Series{
+		Type:  chart.BarSeries,
+		Color: color,
+	}
 
+	for _, point := range timeline.Count(starTimes(stargazers), bucket) {
+		series.XValues = append(series.XValues, point.Time)
+		series.YValues = append(series.YValues, float64(point.Count))
+	}
+	if len(series.XValues) == 0 {
+		series.XValues = append(series.XValues, bucket.Truncate(time.Now()))
+		series.YValues = append(series.YValues, 0)
+	}
+	return series
+}
+
+// releaseAnnotations marks every release of the repository. Releases are
+// nice to have, so failing to get them only leaves the chart without them.
+func releaseAnnotations(ctx context.Context, gh *github.GitHub, name string) []chart.Annotation {
+	releases, err := gh.Releases(ctx, name)
+	if err != nil {
+		log.WithError(err).WithField("repo", name).Warn("failed to get releases")
 		return nil
-	})
+	}
+
+	annotations := make([]chart.Annotation, 0, len(releases))
+	for _, release := range releases {
+		annotations = append(annotations, chart.Annotation{
+			X:     release.PublishedAt,
+			Label: release.TagName,
+		})
+	}
+	return annotations
+}
+
+func newChart(params *params, series ...chart.Series) *chart.Chart {
+	return &chart.Chart{
+		Width:      CHART_WIDTH,
+		Height:     CHART_HEIGHT,
+		Styles:     stylesMap[params.Variant],
+		Theme:      themesMap[params.Variant],
+		Background: params.Background,
+		XAxis: chart.XAxis{
+			Name:        "Time",
+			Color:       params.Axis,
+			StrokeWidth: 2,
+		},
+		YAxis: chart.YAxis{
+			Name:        "Stargazers",
+			Color:       params.Axis,
+			StrokeWidth: 2,
+			Scale:       scalesMap[params.Scale],
+		},
+		Series: series,
+	}
 }
 
 func errSvg(err error) string {
@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"github.com/apex/log"
+	"github.com/caarlos0/httperr"
+	"golang.org/x/sync/errgroup"
+	"net/http"
+	"slices"
+	"strarcharts/internal/cache"
+	"strarcharts/internal/chart"
+	"strarcharts/internal/github"
+	"strarcharts/internal/store"
+	"strings"
+	"time"
+)
+
+// GetCompareChart renders the star history of several repositories on the
+// same axes, e.g. /compare.svg?repos=caarlos0/starcharts,caarlos0/env. Charts
+// are cached like GetRepoChart ones.
+func GetCompareChart(gh *github.GitHub, cache cache.Cache, history *store.Store, ttl, freshness time.Duration) http.Handler {
+	charts := chartCache{cache: cache, ttl: ttl, freshness: freshness}
+	return httperr.NewF(func(w http.ResponseWriter, r *http.Request) error {
+		params, err := extractCompareParams(r)
+		if err != nil {
+			log.WithError(err).Error("failed to extract params")
+			return httperr.Wrap(err, http.StatusBadRequest)
+		}
+
+		cacheKey := compareKey(params)
+		log := log.WithField("repos", strings.Join(params.Repos, ",")).WithField("variant", params.Variant)
+		build := func(ctx context.Context) (builtChart, error) {
+			return buildCompareChart(ctx, gh, history, params)
+		}
+
+		if ok, err := charts.serve(w, cacheKey, svgFormat.contentType, build); ok {
+			return err
+		}
+
+		built, err := charts.build(r.Context(), cacheKey, build)
+		if err != nil {
+			log.WithError(err).Error("failed to get stars")
+			return svgFormat.renderError(w, err)
+		}
+		return charts.write(w, svgFormat.contentType, built)
+	})
+}
+
+// buildCompareChart renders the star history of the compared repositories.
+func buildCompareChart(ctx context.Context, gh *github.GitHub, history *store.Store, params *params) (builtChart, error) {
+	log := log.WithField("repos", strings.Join(params.Repos, ",")).WithField("variant", params.Variant)
+	defer log.Trace("collect_stars").Stop(nil)
+
+	series := make([]chart.Series, len(params.Repos))
+	incomplete := make([]string, len(params.Repos))
+	estimated := make([]string, len(params.Repos))
+	var wg errgroup.Group
+	for i, name := range params.Repos {
+		i, name := i, name
+		wg.Go(func() error {
+			repo, err := gh.RepoDetails(ctx, name)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			stars, err := fetchStars(ctx, gh, history, repo)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			if !stars.complete() {
+				incomplete[i] = repo.FullName
+			}
+			if stars.estimate != nil {
+				estimated[i] = repo.FullName
+				series[i] = estimatedSeries(stars.estimate.Points, "")
+				series[i].Name = repo.FullName + " (estimated)"
+				return nil
+			}
+			series[i] = starsSeries(stars.result.Stargazers, "")
+			series[i].Name = repo.FullName
+			return nil
+		})
+	}
+	if err := wg.Wait(); err != nil {
+		return builtChart{}, err
+	}
+
+	graph := newChart(params, series...)
+	incomplete = slices.DeleteFunc(incomplete, isEmpty)
+	estimated = slices.DeleteFunc(estimated, isEmpty)
+	var notices []string
+	if len(incomplete) > 0 {
+		log.WithField("incomplete", strings.Join(incomplete, ",")).Warn("rendering incomplete chart")
+		notices = append(notices, "Incomplete data: "+strings.Join(incomplete, ", "))
+	}
+	if len(estimated) > 0 {
+		notices = append(notices, "Estimated: "+strings.Join(estimated, ", "))
+	}
+	graph.Notice = strings.Join(notices, "; ")
+	defer log.Trace("chart").Stop(nil)
+
+	body := &strings.Builder{}
+	graph.Render(body)
+	return builtChart{body: body.String(), complete: len(incomplete) == 0}, nil
+}
+
+func isEmpty(s string) bool {
+	return s == ""
+}
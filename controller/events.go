@@ -0,0 +1,162 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/apex/log"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strarcharts/internal/github"
+	"strarcharts/internal/jobs"
+	"strconv"
+	"time"
+)
+
+const (
+	// eventsPollInterval is how often the state of the background build is
+	// checked while streaming events.
+	eventsPollInterval = time.Second
+	// eventsKeepAlive is how often a comment is sent to keep idle streams
+	// open through proxies.
+	eventsKeepAlive = 15 * time.Second
+)
+
+type responseWriterKey struct{}
+
+// KeepResponseWriter lets the handlers below reach the response writer given
+// to h, hidden by middlewares that don't unwrap to it, like httplog. Event
+// streams need it to clear their write deadline.
+func KeepResponseWriter(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), responseWriterKey{}, w)))
+	})
+}
+
+// clearWriteDeadline lets the response outlive the server write timeout.
+func clearWriteDeadline(w http.ResponseWriter, r *http.Request) error {
+	if kept, ok := r.Context().Value(responseWriterKey{}).(http.ResponseWriter); ok {
+		w = kept
+	}
+	return http.NewResponseController(w).SetWriteDeadline(time.Time{})
+}
+
+// GetRepoEvents streams the build of a repository chart as server-sent
+// events: a "status" event with a repoStatus whenever a stargazers page is
+// fetched or the background build changes state, then "done" or "failed"
+// once the background build is over, which ends the stream. Events are
+// identified by when the build last changed state, so a stream reopened
+// after a build ended since the last event it got ends right away. Builds
+// that ended before the stream was first opened are left out, the chart
+// requested along with it may have queued another one already.
+func GetRepoEvents(gh *github.GitHub, queue *jobs.Queue) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := fmt.Sprintf("%s/%s", mux.Vars(r)["owner"], mux.Vars(r)["repo"])
+		log := log.WithField("repo", name)
+
+		if err := clearWriteDeadline(w, r); err != nil {
+			log.WithError(err).Error("failed to clear write deadline, the stream will be cut by the server write timeout")
+		}
+		controller := http.NewResponseController(w)
+
+		header := w.Header()
+		header.Add("content-type", "text/event-stream")
+		header.Add("cache-control", "no-cache")
+		header.Add("x-accel-buffering", "no")
+
+		progress, cancel := gh.Subscribe(name)
+		defer cancel()
+
+		job, _ := queue.Status(jobKey(name))
+		send := func(event string, data interface{}) error {
+			bts, err := json.Marshal(data)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", eventID(job), event, bts); err != nil {
+				return err
+			}
+			return controller.Flush()
+		}
+
+		status := repoJobStatus(gh, job, name)
+		if err := send("status", status); err != nil {
+			log.WithError(err).Warn("failed to send event")
+			return
+		}
+		// the build may have ended while the stream was closed.
+		lastID, err := strconv.ParseInt(r.Header.Get("last-event-id"), 10, 64)
+		if event := finishedEvent(status); event != "" && err == nil && eventID(job) > lastID {
+			_ = send(event, status)
+			return
+		}
+
+		poll := time.NewTicker(eventsPollInterval)
+		defer poll.Stop()
+		keepAlive := time.NewTicker(eventsKeepAlive)
+		defer keepAlive.Stop()
+
+		for {
+			var err error
+			select {
+			case <-r.Context().Done():
+				return
+			case <-keepAlive.C:
+				if _, err = fmt.Fprint(w, ": keep-alive\n\n"); err == nil {
+					err = controller.Flush()
+				}
+			case update := <-progress:
+				status.Fetched, status.Total = update.Fetched, update.Total
+				err = send("status", status)
+			case <-poll.C:
+				// a build may have started and finished since the last
+				// poll, so the whole job status is compared.
+				current, _ := queue.Status(jobKey(name))
+				if current == job {
+					continue
+				}
+				job = current
+				status.State, status.Error = jobState(job), job.Error
+				if event := finishedEvent(status); event != "" {
+					_ = send(event, status)
+					return
+				}
+				err = send("status", status)
+			}
+			if err != nil {
+				log.WithError(err).Debug("stream closed")
+				return
+			}
+		}
+	})
+}
+
+// eventID identifies the events sent about the job, which are the same until
+// it changes state.
+func eventID(job jobs.Status) int64 {
+	if job.UpdatedAt.IsZero() {
+		return 0
+	}
+	return job.UpdatedAt.UnixNano()
+}
+
+// jobState is the state of the job as reported by repoStatus, "idle" when no
+// job is known.
+func jobState(job jobs.Status) string {
+	if job.State == "" {
+		return "idle"
+	}
+	return string(job.State)
+}
+
+// finishedEvent is the event ending the stream once the build is over, if it
+// is.
+func finishedEvent(status repoStatus) string {
+	switch jobs.State(status.State) {
+	case jobs.Done:
+		return "done"
+	case jobs.Failed:
+		return "failed"
+	}
+	return ""
+}
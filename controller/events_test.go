@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strarcharts/config"
+	"strarcharts/internal/cache"
+	"strarcharts/internal/github"
+	"strarcharts/internal/jobs"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestGetRepoEventsFinished(t *testing.T) {
+	gh := github.New(config.Config{GithubStargazersFetcher: github.FetcherREST}, cache.NewMemory(0, 0, 0))
+	queue := jobs.New(1, 1, time.Minute, time.Hour)
+	if err := queue.Enqueue(jobKey("o/r"), func(ctx context.Context) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	var job jobs.Status
+	for job.State != jobs.Done {
+		time.Sleep(time.Millisecond)
+		job, _ = queue.Status(jobKey("o/r"))
+	}
+
+	r := mux.NewRouter()
+	r.Path("/{owner}/{repo}/events").Handler(GetRepoEvents(gh, queue))
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	for name, tt := range map[string]struct {
+		lastEventID string
+		done        bool
+	}{
+		"opened":                   {},
+		"reopened after it ended":  {lastEventID: "0", done: true},
+		"reopened once told ended": {lastEventID: strconv.FormatInt(job.UpdatedAt.UnixNano(), 10)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/o/r/events", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.lastEventID != "" {
+				req.Header.Set("last-event-id", tt.lastEventID)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			// the stream ends after the done event, or lasts until the timeout.
+			var events []string
+			scanner := bufio.NewScanner(resp.Body)
+			for scanner.Scan() {
+				if event, ok := strings.CutPrefix(scanner.Text(), "event: "); ok {
+					events = append(events, event)
+				}
+			}
+			done := len(events) > 0 && events[len(events)-1] == "done"
+			if done != tt.done || (done && ctx.Err() != nil) {
+				t.Fatalf("expected done %v, got events %v, %v", tt.done, events, ctx.Err())
+			}
+		})
+	}
+}
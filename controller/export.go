@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/apex/log"
+	"github.com/caarlos0/httperr"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strarcharts/internal/github"
+	"strarcharts/internal/store"
+	"strarcharts/internal/timeline"
+	"strconv"
+	"time"
+)
+
+// starRow is the cumulative count of stars at a given date.
+type starRow struct {
+	Date  time.Time `json:"date"`
+	Count int       `json:"count"`
+}
+
+// GetRepoJSON exports the star history as a JSON array of cumulative rows.
+func GetRepoJSON(gh *github.GitHub, history *store.Store) http.Handler {
+	return getRepoExport(gh, history, func(w http.ResponseWriter, rows []starRow, bucket timeline.Bucket) error {
+		w.Header().Add("content-type", "application/json")
+		return json.NewEncoder(w).Encode(rows)
+	})
+}
+
+// GetRepoCSV exports the star history as CSV with a date,count header.
+func GetRepoCSV(gh *github.GitHub, history *store.Store) http.Handler {
+	return getRepoExport(gh, history, func(w http.ResponseWriter, rows []starRow, bucket timeline.Bucket) error {
+		w.Header().Add("content-type", "text/csv;charset=utf-8")
+		dateFormat := time.RFC3339
+		if bucket != "" {
+			dateFormat = time.DateOnly
+		}
+
+		writer := csv.NewWriter(w)
+		if err := writer.Write([]string{"date", "count"}); err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if err := writer.Write([]string{
+				row.Date.Format(dateFormat),
+				strconv.Itoa(row.Count),
+			}); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	})
+}
+
+func getRepoExport(gh *github.GitHub, history *store.Store, write func(w http.ResponseWriter, rows []starRow, bucket timeline.Bucket) error) http.Handler {
+	return httperr.NewF(func(w http.ResponseWriter, r *http.Request) error {
+		bucket, err := timeline.ParseBucket(r.URL.Query().Get("bucket"), "")
+		if err != nil {
+			return httperr.Wrap(err, http.StatusBadRequest)
+		}
+
+		name := fmt.Sprintf("%s/%s", mux.Vars(r)["owner"], mux.Vars(r)["repo"])
+		log := log.WithField("repo", name)
+		defer log.Trace("export").Stop(nil)
+
+		repo, err := gh.RepoDetails(r.Context(), name)
+		if err != nil {
+			return httperr.Wrap(err, http.StatusBadRequest)
+		}
+		stars, err := fetchStars(r.Context(), gh, history, repo)
+		if err != nil {
+			log.WithError(err).Error("failed to get stars")
+			return httperr.Wrap(err, http.StatusBadGateway)
+		}
+		// exported counts are meant to be exact, so unlike charts, estimates
+		// and gaps fail the request. Stored stars are exact as of their
+		// last sync.
+		if stars.estimate != nil {
+			return httperr.Wrap(github.ErrTooManyStars, http.StatusBadGateway)
+		}
+		if !stars.result.Complete() {
+			log.WithField("failed", len(stars.result.FailedPages)).Error("incomplete stars")
+			return httperr.Wrap(errors.New(incompleteNotice(stars.result)), http.StatusBadGateway)
+		}
+
+		return write(w, starRows(stars.result.Stargazers, bucket), bucket)
+	})
+}
+
+// starRows turns the sorted stargazers into cumulative rows, one per star or
+// one per bucket when bucket is set.
+func starRows(stargazers []github.Stargazer, bucket timeline.Bucket) []starRow {
+	rows := []starRow{}
+	if bucket == "" {
+		for i, star := range stargazers {
+			rows = append(rows, starRow{Date: star.StarredAt, Count: i + 1})
+		}
+		return rows
+	}
+
+	for _, point := range timeline.Cumulative(starTimes(stargazers), bucket) {
+		rows = append(rows, starRow{Date: point.Time, Count: point.Count})
+	}
+	return rows
+}
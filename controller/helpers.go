@@ -1,10 +1,17 @@
 package controller
 
 import (
+	"errors"
 	"fmt"
+	"github.com/apex/log"
 	"github.com/gorilla/mux"
 	"net/http"
 	"regexp"
+	"strarcharts/internal/cache"
+	"strarcharts/internal/chart"
+	"strarcharts/internal/timeline"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -14,6 +21,10 @@ const (
 	index      = "static/templates/index.gohtml"
 )
 
+const maxCompareRepos = 5
+
+var repoExpression = regexp.MustCompile(`^[\w.-]+/[\w.-]+$`)
+
 var colorExpression = regexp.MustCompile("^#([a-fA-F0-9]{6}|[a-fA-F0-9]{3}|[a-fA-F0-9]{8})$")
 
 func extractColor(r *http.Request, name string) (string, error) {
@@ -30,12 +41,24 @@ func extractColor(r *http.Request, name string) (string, error) {
 }
 
 type params struct {
-	Owner      string
-	Repo       string
-	Line       string
-	Background string
-	Axis       string
-	Variant    string
+	Owner       string
+	Repo        string
+	Line        string
+	Background  string
+	Axis        string
+	Variant     string
+	Scale       string
+	Type        string
+	Bucket      timeline.Bucket
+	Annotations string
+	Source      string
+	Repos       []string
+}
+
+var scalesMap = map[string]chart.Scale{
+	"":       chart.LinearScale,
+	"linear": chart.LinearScale,
+	"log":    chart.LogScale,
 }
 
 func extractSvgChartParams(r *http.Request) (*params, error) {
@@ -54,34 +77,132 @@ func extractSvgChartParams(r *http.Request) (*params, error) {
 		return nil, err
 	}
 
+	scale := r.URL.Query().Get("scale")
+	if _, ok := scalesMap[scale]; !ok {
+		return nil, fmt.Errorf("invalid scale: %s", scale)
+	}
+
+	chartType := r.URL.Query().Get("type")
+	if chartType != "" && chartType != "line" && chartType != "bars" {
+		return nil, fmt.Errorf("invalid type: %s", chartType)
+	}
+
+	bucket, err := timeline.ParseBucket(r.URL.Query().Get("bucket"), timeline.Week)
+	if err != nil {
+		return nil, err
+	}
+
+	annotations := r.URL.Query().Get("annotations")
+	if annotations != "" && annotations != "releases" {
+		return nil, fmt.Errorf("invalid annotations: %s", annotations)
+	}
+
+	source := r.URL.Query().Get("source")
+	if source != "" && source != "snapshots" && source != "merged" {
+		return nil, fmt.Errorf("invalid source: %s", source)
+	}
+
 	vars := mux.Vars(r)
 
 	return &params{
-		Owner:      vars["owner"],
-		Repo:       vars["repo"],
-		Background: backgroundColor,
-		Axis:       axisColor,
-		Line:       lineColor,
-		Variant:    r.URL.Query().Get("variant"),
+		Owner:       vars["owner"],
+		Repo:        vars["repo"],
+		Background:  backgroundColor,
+		Axis:        axisColor,
+		Line:        lineColor,
+		Variant:     r.URL.Query().Get("variant"),
+		Scale:       scale,
+		Type:        chartType,
+		Bucket:      bucket,
+		Annotations: annotations,
+		Source:      source,
 	}, nil
 }
 
-func writeSvgHeaders(w http.ResponseWriter) {
+func extractCompareParams(r *http.Request) (*params, error) {
+	params, err := extractSvgChartParams(r)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range strings.Split(r.URL.Query().Get("repos"), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !repoExpression.MatchString(name) {
+			return nil, fmt.Errorf("invalid repository: %s", name)
+		}
+		params.Repos = append(params.Repos, name)
+	}
+
+	if len(params.Repos) == 0 {
+		return nil, fmt.Errorf("missing repos")
+	}
+	if len(params.Repos) > maxCompareRepos {
+		return nil, fmt.Errorf("too many repos, at most %d can be compared", maxCompareRepos)
+	}
+	return params, nil
+}
+
+// getCached reads key into result, reporting whether it was cached. Backend
+// errors are logged and handled as a miss, the caller can always rebuild.
+func getCached(c cache.Cache, key string, result interface{}) bool {
+	err := c.Get(key, result)
+	if err != nil && !errors.Is(err, cache.ErrCacheMiss) {
+		log.WithError(err).WithField("key", key).Warn("failed to get from cache")
+	}
+	return err == nil
+}
+
+func writeSvgHeaders(w http.ResponseWriter, age, freshness, ttl time.Duration) {
+	writeImageHeaders(w, "image/svg+xml;charset=utf-8", age, freshness, ttl)
+}
+
+// writeImageHeaders lets clients cache an image generated age ago until it is
+// freshness old, and then serve it stale while they revalidate it until it is
+// ttl old. Caches count the age header in, so max-age is the whole freshness.
+func writeImageHeaders(w http.ResponseWriter, contentType string, age, freshness, ttl time.Duration) {
+	stale := max(ttl-max(age, freshness), 0)
+	now := time.Now()
 	header := w.Header()
-	header.Add("content-type", "image/svg+xml;charset=utf-8")
-	header.Add("cache-control", "public, max-age=86400")
-	header.Add("date", time.Now().Format(time.RFC1123))
-	header.Add("expires", time.Now().Format(time.RFC1123))
+	header.Add("content-type", contentType)
+	header.Add("cache-control", fmt.Sprintf("public, max-age=%d, stale-while-revalidate=%d", seconds(freshness), seconds(stale)))
+	header.Add("age", strconv.Itoa(seconds(age)))
+	header.Add("date", now.UTC().Format(http.TimeFormat))
+	header.Add("expires", now.Add(max(freshness-age, 0)).UTC().Format(http.TimeFormat))
+}
+
+func seconds(d time.Duration) int {
+	return int(d / time.Second)
 }
 
 func chartKey(params *params) string {
 	return fmt.Sprintf(
-		"%s/%s/[%s][%s][%s][%s]",
+		"chart/v%d/%s/%s/[%s][%s][%s][%s][%s][%s][%s][%s][%s]",
+		chart.Version,
 		params.Owner,
 		params.Repo,
 		params.Variant,
 		params.Background,
 		params.Axis,
 		params.Line,
+		params.Scale,
+		params.Type,
+		params.Bucket,
+		params.Annotations,
+		params.Source,
+	)
+}
+
+func compareKey(params *params) string {
+	return fmt.Sprintf(
+		"compare/v%d/%s/[%s][%s][%s][%s]",
+		chart.Version,
+		strings.Join(params.Repos, ","),
+		params.Variant,
+		params.Background,
+		params.Axis,
+		params.Scale,
 	)
 }
@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWriteImageHeaders(t *testing.T) {
+	for name, tt := range map[string]struct {
+		age, freshness, ttl       time.Duration
+		wantCacheControl, wantAge string
+	}{
+		"new": {
+			freshness:        time.Hour,
+			ttl:              24 * time.Hour,
+			wantCacheControl: "public, max-age=3600, stale-while-revalidate=82800",
+			wantAge:          "0",
+		},
+		"fresh": {
+			age:              40 * time.Minute,
+			freshness:        time.Hour,
+			ttl:              24 * time.Hour,
+			wantCacheControl: "public, max-age=3600, stale-while-revalidate=82800",
+			wantAge:          "2400",
+		},
+		"stale": {
+			age:              2 * time.Hour,
+			freshness:        time.Hour,
+			ttl:              24 * time.Hour,
+			wantCacheControl: "public, max-age=3600, stale-while-revalidate=79200",
+			wantAge:          "7200",
+		},
+		"past ttl": {
+			age:              25 * time.Hour,
+			freshness:        time.Hour,
+			ttl:              24 * time.Hour,
+			wantCacheControl: "public, max-age=3600, stale-while-revalidate=0",
+			wantAge:          "90000",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			writeImageHeaders(w, "image/png", tt.age, tt.freshness, tt.ttl)
+			if got := w.Header().Get("cache-control"); got != tt.wantCacheControl {
+				t.Errorf("expected cache-control %q, got %q", tt.wantCacheControl, got)
+			}
+			if got := w.Header().Get("age"); got != tt.wantAge {
+				t.Errorf("expected age %q, got %q", tt.wantAge, got)
+			}
+		})
+	}
+}
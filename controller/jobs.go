@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/caarlos0/httperr"
+	"github.com/gorilla/mux"
+	"golang.org/x/sync/errgroup"
+	"net/http"
+	"strarcharts/internal/chart"
+	"strarcharts/internal/github"
+	"strarcharts/internal/jobs"
+	"strings"
+	"sync"
+)
+
+// repoStatus tells how far the background build of a repository chart got.
+type repoStatus struct {
+	State   string `json:"state"`
+	Error   string `json:"error,omitempty"`
+	Fetched int    `json:"fetched"`
+	Total   int    `json:"total"`
+}
+
+// jobKey identifies the background build of a repository chart.
+func jobKey(name string) string {
+	return "chart/" + strings.ToLower(name)
+}
+
+// pendingBuilds are the chart builds waiting for the background job of their
+// repository, by job key and then by chart cache key. One job builds every
+// chart of the repository requested until it runs, be it another variant or
+// format.
+var pendingBuilds = struct {
+	lock   sync.Mutex
+	builds map[string]map[string]func(ctx context.Context) error
+}{builds: map[string]map[string]func(ctx context.Context) error{}}
+
+// queueBuild adds the build of the chart at cacheKey to the background job
+// of the repository, queuing the job unless it is queued already. A job
+// running already is queued again by the queue, so the build isn't missed.
+func queueBuild(queue *jobs.Queue, name, cacheKey string, build func(ctx context.Context) error) error {
+	key := jobKey(name)
+	pendingBuilds.lock.Lock()
+	if pendingBuilds.builds[key] == nil {
+		pendingBuilds.builds[key] = map[string]func(ctx context.Context) error{}
+	}
+	pendingBuilds.builds[key][cacheKey] = build
+	pendingBuilds.lock.Unlock()
+
+	err := queue.Enqueue(key, func(ctx context.Context) error {
+		pendingBuilds.lock.Lock()
+		builds := pendingBuilds.builds[key]
+		delete(pendingBuilds.builds, key)
+		pendingBuilds.lock.Unlock()
+
+		// builds run together so they share the stargazers fetch.
+		var wg errgroup.Group
+		for _, build := range builds {
+			wg.Go(func() error { return build(ctx) })
+		}
+		return wg.Wait()
+	})
+	if err != nil {
+		pendingBuilds.lock.Lock()
+		delete(pendingBuilds.builds[key], cacheKey)
+		pendingBuilds.lock.Unlock()
+	}
+	return err
+}
+
+// GetRepoStatus reports the state of the background build of a repository
+// chart along with how many stargazer pages were fetched so far, "idle"
+// meaning no build is known.
+func GetRepoStatus(gh *github.GitHub, queue *jobs.Queue) http.Handler {
+	return httperr.NewF(func(w http.ResponseWriter, r *http.Request) error {
+		name := fmt.Sprintf("%s/%s", mux.Vars(r)["owner"], mux.Vars(r)["repo"])
+		w.Header().Add("content-type", "application/json")
+		w.Header().Add("cache-control", "no-cache")
+		return json.NewEncoder(w).Encode(repoBuildStatus(gh, queue, name))
+	})
+}
+
+func repoBuildStatus(gh *github.GitHub, queue *jobs.Queue, name string) repoStatus {
+	job, _ := queue.Status(jobKey(name))
+	return repoJobStatus(gh, job, name)
+}
+
+// repoJobStatus reports the state of the job along with the progress of the
+// repository.
+func repoJobStatus(gh *github.GitHub, job jobs.Status, name string) repoStatus {
+	status := repoStatus{State: jobState(job), Error: job.Error}
+	if progress, ok := gh.Progress(name); ok {
+		status.Fetched = progress.Fetched
+		status.Total = progress.Total
+	}
+	return status
+}
+
+// pendingPlaceholder stands in for the chart of a repository whose
+// stargazers are being fetched in the background.
+func pendingPlaceholder(gh *github.GitHub, params *params, name string) *chart.Placeholder {
+	placeholder := &chart.Placeholder{
+		Message:    "Fetching stargazers, waiting for a worker...",
+		Progress:   0,
+		Width:      CHART_WIDTH,
+		Height:     CHART_HEIGHT,
+		Styles:     stylesMap[params.Variant],
+		Theme:      themesMap[params.Variant],
+		Background: params.Background,
+	}
+	if progress, ok := gh.Progress(name); ok && progress.Total > 0 {
+		placeholder.Message = fmt.Sprintf("Fetching stargazers: %d of %d pages", progress.Fetched, progress.Total)
+		placeholder.Progress = float64(progress.Fetched) / float64(progress.Total)
+	}
+	return placeholder
+}
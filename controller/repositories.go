@@ -16,7 +16,7 @@ const (
 	CHART_HEIGHT = 400
 )
 
-func GetRepo(fsys fs.FS, gh *github.GitHub, cache *cache.Redis, version string) http.Handler {
+func GetRepo(fsys fs.FS, gh *github.GitHub, cache cache.Cache, version string) http.Handler {
 	repositoryTemplate, err := template.ParseFS(fsys, base, repository)
 
 	if err != nil {
@@ -31,6 +31,7 @@ func GetRepo(fsys fs.FS, gh *github.GitHub, cache *cache.Redis, version string)
 		name := fmt.Sprintf(
 			"%s/%s",
 			mux.Vars(r)["owner"],
+			mux.Vars(r)["repo"],
 		)
 		details, err := gh.RepoDetails(r.Context(), name)
 		if err != nil {
@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strarcharts/internal/chart"
+	"strarcharts/internal/github"
+	"strarcharts/internal/store"
+	"strarcharts/internal/timeline"
+	"time"
+)
+
+var errNoSnapshots = errors.New("no star count snapshots recorded for this repository yet")
+
+// snapshotPoints returns the star counts recorded daily for the repository.
+func snapshotPoints(history *store.Store, repo github.Repository) ([]timeline.Point, error) {
+	counts, err := history.DailyCounts(repo.FullName)
+	if err != nil {
+		return nil, err
+	}
+	if len(counts) == 0 {
+		return nil, errNoSnapshots
+	}
+	points := make([]timeline.Point, 0, len(counts))
+	for _, count := range counts {
+		points = append(points, timeline.Point{Time: count.Time, Count: count.Count})
+	}
+	return points, nil
+}
+
+// pointsChart builds the chart of cumulative stars, as a line or as the net
+// change per bucket, which is negative when more stars were removed than
+// added.
+func pointsChart(points []timeline.Point, params *params) *chart.Chart {
+	if params.Type == "bars" {
+		graph := newChart(params, spreadBarsSeries(points, params.Bucket, params.Line))
+		graph.YAxis.Name = fmt.Sprintf("Stars per %s", params.Bucket)
+		return graph
+	}
+
+	series := pointsSeries(points, params.Line)
+	if len(series.XValues) < 2 {
+		series.XValues = append(series.XValues, time.Now())
+		series.YValues = append(series.YValues, series.YValues[0])
+	}
+	return newChart(params, series)
+}
+
+// mergedChart builds the chart of the stargazers up to the first snapshot,
+// followed by the snapshots. It also tells whether the chart is complete,
+// like starsChart.
+func mergedChart(stars repoStars, snapshots []timeline.Point, params *params) (*chart.Chart, bool) {
+	graph := pointsChart(mergePoints(starsPoints(stars), snapshots), params)
+	switch {
+	case stars.estimate != nil && !stars.complete():
+		graph.Notice = partialEstimateNotice(*stars.estimate)
+	case stars.estimate != nil:
+		graph.Notice = estimatedNotice
+	case stars.stored:
+		graph.Notice = storedNotice
+	case !stars.complete():
+		graph.Notice = incompleteNotice(stars.result)
+	}
+	return graph, stars.complete()
+}
+
+// starsPoints returns the cumulative stars of the repository, one point per
+// stargazer or the estimate.
+func starsPoints(stars repoStars) []timeline.Point {
+	if stars.estimate != nil {
+		return stars.estimate.Points
+	}
+	points := make([]timeline.Point, 0, len(stars.result.Stargazers))
+	for i, star := range stars.result.Stargazers {
+		points = append(points, timeline.Point{Time: star.StarredAt, Count: i + 1})
+	}
+	return points
+}
+
+// mergePoints keeps the reconstructed points that come before the first
+// snapshot, the snapshots are right about the rest.
+func mergePoints(reconstructed, snapshots []timeline.Point) []timeline.Point {
+	first := snapshots[0].Time
+	i := sort.Search(len(reconstructed), func(i int) bool {
+		return !reconstructed[i].Time.Before(first)
+	})
+	return append(reconstructed[:i:i], snapshots...)
+}
@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"github.com/apex/log"
+	"net/http"
+	"strarcharts/internal/cache"
+	"strarcharts/internal/coalesce"
+	"time"
+)
+
+// cachedChart is a rendered chart along with when it was generated, so it can
+// be served stale while a fresh one is built.
+type cachedChart struct {
+	Body        string
+	GeneratedAt time.Time
+}
+
+// builtChart is a freshly rendered chart, incomplete ones aren't cached.
+type builtChart struct {
+	body     string
+	complete bool
+}
+
+// chartBuilder renders a chart.
+type chartBuilder func(ctx context.Context) (builtChart, error)
+
+var buildFlights = coalesce.New[builtChart]("chart_build")
+
+// incompleteTTL is how long incomplete charts are kept, so the requests
+// following a background build get them instead of queuing another one.
+const incompleteTTL = 5 * time.Minute
+
+// chartCache keeps rendered charts for ttl. Charts older than freshness are
+// still served, but rebuilt in the background.
+type chartCache struct {
+	cache     cache.Cache
+	ttl       time.Duration
+	freshness time.Duration
+}
+
+// serve writes the chart cached at key, if any, triggering a rebuild when it
+// is stale.
+func (c chartCache) serve(w http.ResponseWriter, key, contentType string, build chartBuilder) (bool, error) {
+	var cached cachedChart
+	if !getCached(c.cache, key, &cached) {
+		return false, nil
+	}
+
+	age := time.Since(cached.GeneratedAt)
+	if age >= c.freshness {
+		log.WithField("key", key).WithField("age", age).Debug("serving stale chart")
+		go func() {
+			if _, err := c.build(context.Background(), key, build); err != nil {
+				log.WithError(err).WithField("key", key).Error("failed to rebuild chart")
+			}
+		}()
+	} else {
+		log.WithField("key", key).Debug("using cached chart")
+	}
+
+	c.writeHeaders(w, contentType, age)
+	_, err := fmt.Fprint(w, cached.Body)
+	return true, err
+}
+
+// serveIncomplete writes the incomplete chart last built at key, if any.
+func (c chartCache) serveIncomplete(w http.ResponseWriter, key, contentType string) (bool, error) {
+	var cached cachedChart
+	if !getCached(c.cache, incompleteKey(key), &cached) {
+		return false, nil
+	}
+	log.WithField("key", key).Debug("using incomplete chart")
+	return true, c.write(w, contentType, builtChart{body: cached.Body})
+}
+
+// build renders the chart, sharing the work with concurrent builds of the
+// same key, and caches it for ttl when complete, for incompleteTTL apart
+// otherwise.
+func (c chartCache) build(ctx context.Context, key string, build chartBuilder) (builtChart, error) {
+	return buildFlights.Do(ctx, key, func(ctx context.Context) (builtChart, error) {
+		built, err := build(ctx)
+		if err != nil {
+			return built, err
+		}
+		cacheKey, ttl := key, c.ttl
+		if !built.complete {
+			cacheKey, ttl = incompleteKey(key), incompleteTTL
+		}
+		if err := c.cache.Put(cacheKey, cachedChart{
+			Body:        built.body,
+			GeneratedAt: time.Now(),
+		}, ttl); err != nil {
+			log.WithError(err).WithField("key", cacheKey).Error("failed to cache chart")
+		}
+		return built, nil
+	})
+}
+
+// write writes a chart that was just built. Incomplete charts are not to be
+// cached by clients either, so the next request tries the missing parts again.
+func (c chartCache) write(w http.ResponseWriter, contentType string, built builtChart) error {
+	c.writeHeaders(w, contentType, 0)
+	if !built.complete {
+		w.Header().Set("cache-control", "no-cache")
+	}
+	_, err := fmt.Fprint(w, built.body)
+	return err
+}
+
+// incompleteKey is where the incomplete chart of key is kept.
+func incompleteKey(key string) string {
+	return key + ".incomplete"
+}
+
+func (c chartCache) writeHeaders(w http.ResponseWriter, contentType string, age time.Duration) {
+	writeImageHeaders(w, contentType, age, c.freshness, c.ttl)
+}
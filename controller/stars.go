@@ -0,0 +1,123 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"github.com/apex/log"
+	"strarcharts/internal/coalesce"
+	"strarcharts/internal/github"
+	"strarcharts/internal/store"
+	"time"
+)
+
+// repoStars is what the charts and exports of a repository are built from.
+type repoStars struct {
+	repo   github.Repository
+	result github.StargazersResult
+	// estimate is only set for repositories above the listing cap, result
+	// is empty then.
+	estimate *github.EstimateResult
+	// stored is set when GitHub failed and the stargazers come from the
+	// store alone, as of its last sync.
+	stored bool
+}
+
+var starsFlights = coalesce.New[repoStars]("controller")
+
+// fetchStars gets the stargazers of the repository, estimating them above the
+// listing cap. Fetched stargazers are kept in the store, which the result is
+// read from, so stars stored before fill the pages that failed and stand in
+// for GitHub when it fails altogether. Concurrent requests for the same
+// repository share the fetch, whatever chart options they asked for.
+func fetchStars(ctx context.Context, gh *github.GitHub, history *store.Store, repo github.Repository) (repoStars, error) {
+	return starsFlights.Do(ctx, repo.FullName, func(ctx context.Context) (repoStars, error) {
+		log := log.WithField("repo", repo.FullName)
+		stars := repoStars{repo: repo}
+		result, err := gh.Stargazers(ctx, repo)
+		if errors.Is(err, github.ErrTooManyStars) {
+			log.Info("too many stars, estimating")
+			estimate, err := gh.EstimatedStargazers(ctx, repo)
+			stars.estimate = &estimate
+			return stars, err
+		}
+		// abandoned fetches aren't GitHub failures, stored stars would
+		// only make an incomplete chart nobody waits for.
+		if errors.Is(err, context.Canceled) {
+			return stars, err
+		}
+		if err != nil {
+			stored, serr := history.Stars(repo.FullName)
+			if serr != nil || len(stored) == 0 {
+				return stars, err
+			}
+			log.WithError(err).Warn("failed to get stars, using stored ones")
+			stars.result = github.StargazersResult{Stargazers: fromStore(stored)}
+			stars.stored = true
+			return stars, nil
+		}
+
+		stars.result = result
+		stored, err := storeStars(history, repo, result)
+		if err != nil {
+			log.WithError(err).Warn("failed to store stars")
+			return stars, nil
+		}
+		stars.result.Stargazers = fromStore(stored)
+		if !result.Complete() && len(stored) >= repo.StargazersCount {
+			log.WithField("failed", len(result.FailedPages)).Info("failed pages covered by stored stars")
+			stars.result.FailedPages = nil
+		}
+		return stars, nil
+	})
+}
+
+// storeStars keeps the fetched stargazers and the star count in the store,
+// and returns the stored stars. Only complete listings tell which stars were
+// removed.
+func storeStars(history *store.Store, repo github.Repository, result github.StargazersResult) ([]store.Star, error) {
+	if err := history.RecordCount(repo.FullName, time.Now(), repo.StargazersCount); err != nil {
+		return nil, err
+	}
+	if result.Complete() {
+		added, removed, err := history.SyncStars(repo.FullName, toStore(result.Stargazers))
+		if err != nil {
+			return nil, err
+		}
+		log.WithField("repo", repo.FullName).WithField("added", added).WithField("removed", removed).Debug("synced stored stars")
+	} else if _, err := history.AddStars(repo.FullName, toStore(result.Stargazers)); err != nil {
+		return nil, err
+	}
+	return history.Stars(repo.FullName)
+}
+
+func toStore(stargazers []github.Stargazer) []store.Star {
+	stars := make([]store.Star, 0, len(stargazers))
+	for _, stargazer := range stargazers {
+		stars = append(stars, store.Star{
+			StarredAt: stargazer.StarredAt,
+			Login:     stargazer.User.Login,
+			ID:        stargazer.User.ID,
+		})
+	}
+	return stars
+}
+
+func fromStore(stars []store.Star) []github.Stargazer {
+	stargazers := make([]github.Stargazer, 0, len(stars))
+	for _, star := range stars {
+		stargazers = append(stargazers, github.Stargazer{
+			StarredAt: star.StarredAt,
+			User:      github.User{Login: star.Login, ID: star.ID},
+		})
+	}
+	return stargazers
+}
+
+// complete reports whether nothing is missing from the stargazers, or from
+// the samples of the estimate.
+func (s repoStars) complete() bool {
+	if s.estimate != nil {
+		return s.estimate.Complete()
+	}
+	return s.result.Complete() && !s.stored
+}
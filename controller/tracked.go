@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/caarlos0/httperr"
+	"github.com/gorilla/mux"
+	"golang.org/x/sync/errgroup"
+	"net/http"
+	"strarcharts/internal/cache"
+	"strarcharts/internal/github"
+	"strarcharts/internal/store"
+	"strarcharts/internal/timeline"
+	"strarcharts/internal/tracked"
+	"strings"
+	"time"
+)
+
+var (
+	errAdminDisabled = errors.New("admin api is disabled")
+	errUnauthorized  = errors.New("invalid admin token")
+	errNotTracked    = errors.New("repository is not tracked")
+	errIncomplete    = errors.New("chart is incomplete, it wasn't cached")
+)
+
+// warmVariants are the chart variants kept warm for tracked repositories,
+// the default one embedded in READMEs and the one of the repository page.
+var warmVariants = []string{"", "adaptive"}
+
+// WarmRepoCharts refreshes the details and stargazers of a repository, and
+// caches its default charts again, as if they were just requested.
+func WarmRepoCharts(gh *github.GitHub, cache cache.Cache, history *store.Store, ttl, freshness time.Duration) tracked.Refresher {
+	charts := chartCache{cache: cache, ttl: ttl, freshness: freshness}
+	return func(ctx context.Context, name string) error {
+		repo, err := gh.RepoDetails(ctx, name)
+		if err != nil {
+			return err
+		}
+
+		// keys are made from the name as requested, not as GitHub has it.
+		owner, repoName, _ := strings.Cut(name, "/")
+		var wg errgroup.Group
+		for _, variant := range warmVariants {
+			params := &params{Owner: owner, Repo: repoName, Variant: variant, Bucket: timeline.Week}
+			wg.Go(func() error {
+				built, err := charts.build(ctx, chartKey(params)+"."+svgFormat.extension, func(ctx context.Context) (builtChart, error) {
+					return buildRepoChart(ctx, gh, history, repo, params, svgFormat)
+				})
+				if err == nil && !built.complete {
+					return errIncomplete
+				}
+				return err
+			})
+		}
+		return wg.Wait()
+	}
+}
+
+// GetTracked lists the tracked repositories as a JSON array.
+func GetTracked(list *tracked.List, token string) http.Handler {
+	return requireAdmin(token, func(w http.ResponseWriter, r *http.Request) error {
+		return writeTracked(w, http.StatusOK, list)
+	})
+}
+
+// AddTracked tracks the repository sent as {"repo": "owner/repo"}, which is
+// refreshed right away, and responds with the tracked repositories.
+func AddTracked(list *tracked.List, token string) http.Handler {
+	return requireAdmin(token, func(w http.ResponseWriter, r *http.Request) error {
+		var body struct {
+			Repo string `json:"repo"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			return httperr.Wrap(fmt.Errorf("invalid body: %w", err), http.StatusBadRequest)
+		}
+
+		added, err := list.Add(strings.TrimSpace(body.Repo))
+		if errors.Is(err, tracked.ErrInvalidRepo) {
+			return httperr.Wrap(err, http.StatusBadRequest)
+		}
+		if err != nil {
+			return err
+		}
+		status := http.StatusOK
+		if added {
+			status = http.StatusCreated
+		}
+		return writeTracked(w, status, list)
+	})
+}
+
+// RemoveTracked stops tracking the repository of the path.
+func RemoveTracked(list *tracked.List, token string) http.Handler {
+	return requireAdmin(token, func(w http.ResponseWriter, r *http.Request) error {
+		name := fmt.Sprintf("%s/%s", mux.Vars(r)["owner"], mux.Vars(r)["repo"])
+		removed, err := list.Remove(name)
+		if err != nil {
+			return err
+		}
+		if !removed {
+			return httperr.Wrap(errNotTracked, http.StatusNotFound)
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+}
+
+// requireAdmin only lets requests bearing the admin token through, an empty
+// token disables the admin API altogether.
+func requireAdmin(token string, next func(w http.ResponseWriter, r *http.Request) error) http.Handler {
+	return httperr.NewF(func(w http.ResponseWriter, r *http.Request) error {
+		if token == "" {
+			return httperr.Wrap(errAdminDisabled, http.StatusNotFound)
+		}
+		given, _ := strings.CutPrefix(r.Header.Get("authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			return httperr.Wrap(errUnauthorized, http.StatusUnauthorized)
+		}
+		return next(w, r)
+	})
+}
+
+func writeTracked(w http.ResponseWriter, status int, list *tracked.List) error {
+	w.Header().Add("content-type", "application/json")
+	w.Header().Add("cache-control", "no-cache")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(append([]string{}, list.Repos()...))
+}
@@ -1,18 +1,47 @@
 package cache
 
 import (
+	"errors"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/vmihailenco/msgpack"
+	"time"
 )
-import rediscache "github.com/go-redis/cache"
-import "github.com/go-redis/redis"
+
+// ErrCacheMiss is returned by Cache.Get when the key is not cached.
+var ErrCacheMiss = errors.New("cache: key is missing")
 
 var cacheGets = prometheus.NewCounter(
 	prometheus.CounterOpts{
 		Namespace: "starcharts",
 		Subsystem: "cache",
 		Name:      "gets_total",
-		Help:      "Total number of successful cache puts",
+		Help:      "Total number of cache gets",
+	},
+)
+
+var cacheHits = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Namespace: "starcharts",
+		Subsystem: "cache",
+		Name:      "hits_total",
+		Help:      "Total number of cache gets that found the key",
+	},
+)
+
+var cacheMisses = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Namespace: "starcharts",
+		Subsystem: "cache",
+		Name:      "misses_total",
+		Help:      "Total number of cache gets that didn't find the key",
+	},
+)
+
+var cacheErrors = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Namespace: "starcharts",
+		Subsystem: "cache",
+		Name:      "errors_total",
+		Help:      "Total number of cache operations that failed",
 	},
 )
 
@@ -34,58 +63,34 @@ var cacheDeletes = prometheus.NewCounter(
 	},
 )
 
-func init() {
-	prometheus.MustRegister(cacheGets, cachePuts, cacheDeletes)
-}
-
-type Redis struct {
-	redis *redis.Client
-	codec *rediscache.Codec
-}
-
-func New(redis *redis.Client) *Redis {
-	//rediscache.Codec is used for encoding and decoding Redis cached data
-	codec := &rediscache.Codec{Redis: redis, //redis client instance
-		Marshal: func(v interface{}) ([]byte, error) {
-			return msgpack.Marshal(v) //serialization function
-		},
-		Unmarshal: func(b []byte, v interface{}) error {
-			return msgpack.Unmarshal(b, v)
-		}, //deserialization function
-	}
-	return &Redis{
-		redis: redis,
-		codec: codec,
-	}
-}
-
-func (c *Redis) Close() error {
-	return c.redis.Close()
-}
-
-func (c *Redis) Get(key string, result interface{}) error {
-	if err := c.codec.Get(key, result); err != nil {
-		return nil
-	}
-	cacheGets.Inc()
-	return nil
-}
+var cacheEvictions = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Namespace: "starcharts",
+		Subsystem: "cache",
+		Name:      "evictions_total",
+		Help:      "Total number of entries evicted to honor the memory cache limits",
+	},
+)
 
-func (c *Redis) Put(key string, obj interface{}) error {
-	if err := c.codec.Set(&rediscache.Item{
-		Key:    key,
-		Object: obj,
-	}); err != nil {
-		return err
-	}
-	cachePuts.Inc()
-	return nil
+func init() {
+	prometheus.MustRegister(cacheGets, cacheHits, cacheMisses, cacheErrors, cachePuts, cacheDeletes, cacheEvictions)
 }
 
-func (c *Redis) Delete(key string) error {
-	if err := c.codec.Delete(key); err != nil {
-		return err
-	}
-	cacheDeletes.Inc()
-	return nil
+// Cache stores msgpack encodable values by key.
+type Cache interface {
+	// Get decodes the value stored at key into result, returning
+	// ErrCacheMiss if there is none. Any other error comes from the backend.
+	Get(key string, result interface{}) error
+	// Put stores obj at key for ttl. Zero means the default of the backend,
+	// an hour with Redis and the TTL given to NewMemory with Memory, while a
+	// negative ttl means it doesn't expire.
+	Put(key string, obj interface{}, ttl time.Duration) error
+	// Delete removes key, deleting a missing key is not an error.
+	Delete(key string) error
+	// Lock takes the lock named key for ttl, returning ErrLockHeld if
+	// someone else holds it. Locks are shared by everyone using the same
+	// backend.
+	Lock(key string, ttl time.Duration) (Lock, error)
+	// Close releases the resources held by the cache.
+	Close() error
 }
@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrLockHeld is returned by Cache.Lock when someone else holds the lock.
+	ErrLockHeld = errors.New("cache: lock is held")
+	// ErrLockLost is returned when a lock expired and was taken by someone
+	// else before it was refreshed or released.
+	ErrLockLost = errors.New("cache: lock was lost")
+)
+
+// Lock is a lock taken with Cache.Lock, expiring unless refreshed so that a
+// crashed holder doesn't keep it forever.
+type Lock interface {
+	// Refresh extends the lock for another ttl.
+	Refresh(ttl time.Duration) error
+	// Release gives up the lock.
+	Release() error
+}
+
+// newLockToken identifies a lock holder, so a lock that expired and was taken
+// by someone else is never released or extended by its previous holder.
+func newLockToken() (string, error) {
+	bts := make([]byte, 16)
+	if _, err := rand.Read(bts); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bts), nil
+}
+
+// memoryLocks are the locks of a Memory cache, only shared within the process.
+type memoryLocks struct {
+	lock  sync.Mutex
+	locks map[string]memoryLockEntry
+}
+
+type memoryLockEntry struct {
+	token     string
+	expiresAt time.Time
+}
+
+func (l *memoryLocks) acquire(key string, ttl time.Duration) (Lock, error) {
+	token, err := newLockToken()
+	if err != nil {
+		return nil, err
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if entry, ok := l.locks[key]; ok && time.Now().Before(entry.expiresAt) {
+		return nil, ErrLockHeld
+	}
+	if l.locks == nil {
+		l.locks = map[string]memoryLockEntry{}
+	}
+	l.locks[key] = memoryLockEntry{token: token, expiresAt: time.Now().Add(ttl)}
+	return &memoryLock{locks: l, key: key, token: token}, nil
+}
+
+type memoryLock struct {
+	locks *memoryLocks
+	key   string
+	token string
+}
+
+func (m *memoryLock) Refresh(ttl time.Duration) error {
+	m.locks.lock.Lock()
+	defer m.locks.lock.Unlock()
+	if entry, ok := m.locks.locks[m.key]; !ok || entry.token != m.token {
+		return ErrLockLost
+	}
+	m.locks.locks[m.key] = memoryLockEntry{token: m.token, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (m *memoryLock) Release() error {
+	m.locks.lock.Lock()
+	defer m.locks.lock.Unlock()
+	if entry, ok := m.locks.locks[m.key]; !ok || entry.token != m.token {
+		return ErrLockLost
+	}
+	delete(m.locks.locks, m.key)
+	return nil
+}
@@ -0,0 +1,141 @@
+package cache
+
+import (
+	"container/list"
+	"github.com/vmihailenco/msgpack"
+	"sync"
+	"time"
+)
+
+// Memory is an in-process LRU cache. Values are stored msgpack encoded, so
+// they are copied in and out just like with Redis.
+type Memory struct {
+	maxItems int
+	maxBytes int64
+	ttl      time.Duration
+
+	lock    sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	size    int64
+
+	locks memoryLocks
+}
+
+type memoryEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// NewMemory creates a Memory cache holding at most maxItems entries and
+// maxBytes of encoded values, zero meaning no limit. Entries put without a
+// TTL expire after ttl, zero meaning never.
+func NewMemory(maxItems int, maxBytes int64, ttl time.Duration) *Memory {
+	return &Memory{
+		maxItems: maxItems,
+		maxBytes: maxBytes,
+		ttl:      ttl,
+		entries:  map[string]*list.Element{},
+		lru:      list.New(),
+	}
+}
+
+// Lock takes a lock only shared within the process.
+func (c *Memory) Lock(key string, ttl time.Duration) (Lock, error) {
+	return c.locks.acquire(key, ttl)
+}
+
+func (c *Memory) Close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.entries = map[string]*list.Element{}
+	c.lru.Init()
+	c.size = 0
+	return nil
+}
+
+func (c *Memory) Get(key string, result interface{}) error {
+	cacheGets.Inc()
+	c.lock.Lock()
+	element, ok := c.entries[key]
+	if !ok {
+		c.lock.Unlock()
+		cacheMisses.Inc()
+		return ErrCacheMiss
+	}
+	entry := element.Value.(*memoryEntry)
+	if !entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt) {
+		c.remove(element)
+		c.lock.Unlock()
+		cacheMisses.Inc()
+		return ErrCacheMiss
+	}
+	c.lru.MoveToFront(element)
+	value := entry.value
+	c.lock.Unlock()
+
+	if err := msgpack.Unmarshal(value, result); err != nil {
+		cacheErrors.Inc()
+		return err
+	}
+	cacheHits.Inc()
+	return nil
+}
+
+func (c *Memory) Put(key string, obj interface{}, ttl time.Duration) error {
+	value, err := msgpack.Marshal(obj)
+	if err != nil {
+		cacheErrors.Inc()
+		return err
+	}
+
+	entry := &memoryEntry{
+		key:   key,
+		value: value,
+	}
+	if ttl == 0 {
+		ttl = c.ttl
+	}
+	if ttl > 0 {
+		entry.expiresAt = time.Now().Add(ttl)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	c.size += int64(len(value))
+	c.evict()
+	cachePuts.Inc()
+	return nil
+}
+
+func (c *Memory) Delete(key string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+	cacheDeletes.Inc()
+	return nil
+}
+
+// evict drops the least recently used entries until the limits are met.
+// Must be called with the lock held.
+func (c *Memory) evict() {
+	for c.lru.Len() > 0 &&
+		((c.maxItems > 0 && c.lru.Len() > c.maxItems) || (c.maxBytes > 0 && c.size > c.maxBytes)) {
+		c.remove(c.lru.Back())
+		cacheEvictions.Inc()
+	}
+}
+
+// remove drops element from the cache. Must be called with the lock held.
+func (c *Memory) remove(element *list.Element) {
+	entry := c.lru.Remove(element).(*memoryEntry)
+	delete(c.entries, entry.key)
+	c.size -= int64(len(entry.value))
+}
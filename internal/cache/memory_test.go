@@ -0,0 +1,172 @@
+package cache
+
+import (
+	"errors"
+	"github.com/vmihailenco/msgpack"
+	"testing"
+	"time"
+)
+
+// step is an operation on the cache, a put unless get or del is set.
+type step struct {
+	key string
+	get bool
+	del bool
+}
+
+func putKey(key string) step    { return step{key: key} }
+func getKey(key string) step    { return step{key: key, get: true} }
+func deleteKey(key string) step { return step{key: key, del: true} }
+
+func TestMemoryEviction(t *testing.T) {
+	// every value is its key, all the keys have the same length.
+	size, err := msgpack.Marshal("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	valueSize := int64(len(size))
+
+	for name, tt := range map[string]struct {
+		maxItems int
+		maxBytes int64
+		steps    []step
+		want     []string
+		missing  []string
+	}{
+		"no limit": {
+			steps: []step{putKey("a"), putKey("b"), putKey("c")},
+			want:  []string{"a", "b", "c"},
+		},
+		"max items": {
+			maxItems: 2,
+			steps:    []step{putKey("a"), putKey("b"), putKey("c")},
+			want:     []string{"b", "c"},
+			missing:  []string{"a"},
+		},
+		"max bytes": {
+			maxBytes: 2 * valueSize,
+			steps:    []step{putKey("a"), putKey("b"), putKey("c")},
+			want:     []string{"b", "c"},
+			missing:  []string{"a"},
+		},
+		"get makes recently used": {
+			maxItems: 2,
+			steps:    []step{putKey("a"), putKey("b"), getKey("a"), putKey("c")},
+			want:     []string{"a", "c"},
+			missing:  []string{"b"},
+		},
+		"put again makes recently used": {
+			maxItems: 2,
+			steps:    []step{putKey("a"), putKey("b"), putKey("a"), putKey("c")},
+			want:     []string{"a", "c"},
+			missing:  []string{"b"},
+		},
+		"put again doesn't count twice": {
+			maxBytes: 2 * valueSize,
+			steps:    []step{putKey("a"), putKey("a"), putKey("a"), putKey("b")},
+			want:     []string{"a", "b"},
+		},
+		"delete frees room": {
+			maxItems: 2,
+			steps:    []step{putKey("a"), putKey("b"), deleteKey("a"), putKey("c")},
+			want:     []string{"b", "c"},
+			missing:  []string{"a"},
+		},
+		"value above max bytes": {
+			maxBytes: valueSize - 1,
+			steps:    []step{putKey("a")},
+			missing:  []string{"a"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			c := NewMemory(tt.maxItems, tt.maxBytes, 0)
+			for _, step := range tt.steps {
+				var err error
+				switch {
+				case step.get:
+					var value string
+					err = c.Get(step.key, &value)
+				case step.del:
+					err = c.Delete(step.key)
+				default:
+					err = c.Put(step.key, step.key, 0)
+				}
+				if err != nil {
+					t.Fatalf("%+v: %v", step, err)
+				}
+			}
+
+			for _, key := range tt.want {
+				var value string
+				if err := c.Get(key, &value); err != nil || value != key {
+					t.Errorf("%s: expected %q, got %q, %v", key, key, value, err)
+				}
+			}
+			for _, key := range tt.missing {
+				var value string
+				if err := c.Get(key, &value); !errors.Is(err, ErrCacheMiss) {
+					t.Errorf("%s: expected a miss, got %q, %v", key, value, err)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryTTL(t *testing.T) {
+	for name, tt := range map[string]struct {
+		defaultTTL time.Duration
+		ttl        time.Duration
+		expired    bool
+	}{
+		"expired":              {ttl: time.Millisecond, expired: true},
+		"not expired":          {ttl: time.Hour},
+		"default ttl":          {defaultTTL: time.Millisecond, expired: true},
+		"ttl over default ttl": {defaultTTL: time.Millisecond, ttl: time.Hour},
+		"no default ttl":       {},
+		"negative ttl":         {defaultTTL: time.Millisecond, ttl: -1},
+	} {
+		t.Run(name, func(t *testing.T) {
+			c := NewMemory(0, 0, tt.defaultTTL)
+			if err := c.Put("key", "value", tt.ttl); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(5 * time.Millisecond)
+
+			var value string
+			err := c.Get("key", &value)
+			if tt.expired && !errors.Is(err, ErrCacheMiss) {
+				t.Fatalf("expected a miss, got %q, %v", value, err)
+			}
+			if !tt.expired && (err != nil || value != "value") {
+				t.Fatalf("expected the value, got %q, %v", value, err)
+			}
+		})
+	}
+}
+
+func TestMemoryLock(t *testing.T) {
+	c := NewMemory(0, 0, 0)
+	lock, err := c.Lock("key", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Lock("key", time.Hour); !errors.Is(err, ErrLockHeld) {
+		t.Fatalf("expected ErrLockHeld, got %v", err)
+	}
+	if err := lock.Release(); err != nil {
+		t.Fatal(err)
+	}
+	expiring, err := c.Lock("key", time.Millisecond)
+	if err != nil {
+		t.Fatalf("expected the released lock, got %v", err)
+	}
+
+	// an expired lock can be taken by someone else, and is lost to its holder.
+	time.Sleep(5 * time.Millisecond)
+	if _, err := c.Lock("key", time.Hour); err != nil {
+		t.Fatalf("expected the expired lock, got %v", err)
+	}
+	if err := expiring.Release(); !errors.Is(err, ErrLockLost) {
+		t.Fatalf("expected ErrLockLost, got %v", err)
+	}
+}
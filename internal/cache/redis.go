@@ -0,0 +1,130 @@
+package cache
+
+import (
+	"errors"
+	rediscache "github.com/go-redis/cache"
+	"github.com/go-redis/redis"
+	"github.com/vmihailenco/msgpack"
+	"time"
+)
+
+type Redis struct {
+	redis *redis.Client
+	codec *rediscache.Codec
+}
+
+func NewRedis(redis *redis.Client) *Redis {
+	//rediscache.Codec is used for encoding and decoding Redis cached data
+	codec := &rediscache.Codec{Redis: redis, //redis client instance
+		Marshal: func(v interface{}) ([]byte, error) {
+			return msgpack.Marshal(v) //serialization function
+		},
+		Unmarshal: func(b []byte, v interface{}) error {
+			return msgpack.Unmarshal(b, v)
+		}, //deserialization function
+	}
+	return &Redis{
+		redis: redis,
+		codec: codec,
+	}
+}
+
+func (c *Redis) Close() error {
+	return c.redis.Close()
+}
+
+func (c *Redis) Get(key string, result interface{}) error {
+	cacheGets.Inc()
+	err := c.codec.Get(key, result)
+	if errors.Is(err, rediscache.ErrCacheMiss) {
+		cacheMisses.Inc()
+		return ErrCacheMiss
+	}
+	if err != nil {
+		cacheErrors.Inc()
+		return err
+	}
+	cacheHits.Inc()
+	return nil
+}
+
+func (c *Redis) Put(key string, obj interface{}, ttl time.Duration) error {
+	if err := c.codec.Set(&rediscache.Item{
+		Key:        key,
+		Object:     obj,
+		Expiration: ttl,
+	}); err != nil {
+		cacheErrors.Inc()
+		return err
+	}
+	cachePuts.Inc()
+	return nil
+}
+
+func (c *Redis) Delete(key string) error {
+	if err := c.codec.Delete(key); err != nil && !errors.Is(err, rediscache.ErrCacheMiss) {
+		cacheErrors.Inc()
+		return err
+	}
+	cacheDeletes.Inc()
+	return nil
+}
+
+// unlockScript deletes the lock only if it is still held with the token.
+var unlockScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("del", KEYS[1])
+end
+return 0
+`)
+
+// refreshScript extends the lock only if it is still held with the token.
+var refreshScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("pexpire", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// Lock takes a lock shared by every instance using the same Redis.
+func (c *Redis) Lock(key string, ttl time.Duration) (Lock, error) {
+	token, err := newLockToken()
+	if err != nil {
+		return nil, err
+	}
+	ok, err := c.redis.SetNX(key, token, ttl).Result()
+	if err != nil {
+		cacheErrors.Inc()
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrLockHeld
+	}
+	return &redisLock{redis: c.redis, key: key, token: token}, nil
+}
+
+type redisLock struct {
+	redis *redis.Client
+	key   string
+	token string
+}
+
+func (l *redisLock) Refresh(ttl time.Duration) error {
+	return l.run(refreshScript, l.token, ttl.Milliseconds())
+}
+
+func (l *redisLock) Release() error {
+	return l.run(unlockScript, l.token)
+}
+
+func (l *redisLock) run(script *redis.Script, args ...interface{}) error {
+	n, err := script.Run(l.redis, []string{l.key}, args...).Int64()
+	if err != nil {
+		cacheErrors.Inc()
+		return err
+	}
+	if n == 0 {
+		return ErrLockLost
+	}
+	return nil
+}
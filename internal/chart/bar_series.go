@@ -0,0 +1,54 @@
+package chart
+
+// renderBars draws one bar per value, starting at its X value and spanning
+// until the next one. The last bar spans as long as the one before it, and
+// negative values hang below zero.
+func (ts *Series) renderBars(r Renderer, canvasBox *Box, xrange, yrange *Range) {
+	cb := canvasBox.Bottom
+	cl := canvasBox.Left
+	base := cb - yrange.Translate(max(yrange.Min, 0))
+
+	style := Style{
+		ClassName: "series",
+		FillColor: ts.Color,
+	}
+
+	for i := 0; i < ts.Len(); i++ {
+		vx, vy := ts.GetValues(i)
+		if vy == 0 {
+			continue
+		}
+
+		left := cl + xrange.Translate(vx)
+		right := cl + xrange.Translate(vx+ts.barSpan(i))
+		top, bottom := cb-yrange.Translate(vy), base
+		if vy < 0 {
+			top, bottom = base, top
+		}
+
+		// leave a gap between bars as long as they stay visible.
+		if right-left > BarSpacing+1 {
+			right -= BarSpacing
+		}
+
+		r.FillRect(Box{
+			Top:    top,
+			Left:   left,
+			Right:  right,
+			Bottom: bottom,
+		}, 0, style)
+	}
+}
+
+// barSpan is the X distance covered by the bar at index.
+func (ts *Series) barSpan(index int) float64 {
+	if ts.Len() < 2 {
+		return toFloat64(ts.XValues[0].AddDate(0, 0, 1)) - toFloat64(ts.XValues[0])
+	}
+	if index == ts.Len()-1 {
+		index--
+	}
+	x0, _ := ts.GetValues(index)
+	x1, _ := ts.GetValues(index + 1)
+	return x1 - x0
+}
@@ -1,16 +1,43 @@
 package chart
 
+import "time"
+
 type ValueFormatter func(v interface{}) string
 
 type Chart struct {
 	XAxis XAxis
 	YAxis YAxis
 
-	Series Series
+	Series      []Series
+	Annotations []Annotation
+
+	// Notice is a warning shown over the plot, e.g. when the data is
+	// incomplete.
+	Notice string
 
 	Background string
 	Styles     string
+	Theme      Theme
 
 	Width  int
 	Height int
 }
+
+// seriesColor returns the stroke color of the series at index, falling back to
+// SeriesColors for every series after the first one, so that compared series
+// stay distinguishable while a lone series keeps the themed color.
+func (c *Chart) seriesColor(index int) string {
+	if color := c.Series[index].Color; color != "" {
+		return color
+	}
+	if index == 0 {
+		return ""
+	}
+	return SeriesColors[(index-1)%len(SeriesColors)]
+}
+
+// Annotation marks a point in time on the chart, such as a release.
+type Annotation struct {
+	X     time.Time
+	Label string
+}
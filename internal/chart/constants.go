@@ -1,5 +1,10 @@
 package chart
 
+// Version identifies the rendered output. Bump it whenever a renderer change,
+// or a change in how charts are cached, should invalidate the charts cached
+// so far.
+const Version = 3
+
 var BoxPadding = Box{
 	Top:    10,
 	Left:   25,
@@ -24,4 +29,37 @@ const (
 	HorizontalTickWidth = YAxisMargin >> 1
 
 	MinStrokeWidth = 1.0
+
+	BarSpacing = 1
+
+	AnnotationLabelPadding = 3
+	MaxAnnotationRows      = 3
+	MaxAnnotationLabelLen  = 16
+
+	NoticePadding = 4
+
+	PlaceholderBarWidth  = 300
+	PlaceholderBarHeight = 8
+	PlaceholderBarMargin = 12
+)
+
+// AnnotationDashArray is the dash pattern of annotation lines.
+var AnnotationDashArray = []float64{4, 3}
+
+// EstimateDashArray is the dash pattern of estimated series.
+var EstimateDashArray = []float64{8, 4}
+
+const (
+	LegendMargin      = 10
+	LegendSwatchWidth = 16
+	LegendLineSpacing = 6
 )
+
+// SeriesColors is the palette used for series without an explicit color.
+var SeriesColors = []string{
+	"#e76060",
+	"#2f81f7",
+	"#3fb950",
+	"#d29922",
+	"#a371f7",
+}
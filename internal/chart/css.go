@@ -3,6 +3,8 @@ package chart
 const LightStyles = `
 path { fill: none; stroke: rgb(51,51,51); }
 path.series { stroke: #6b63ff; }
+rect.series { fill: #6b63ff; stroke: none; }
+path.annotation { stroke: #8c959f; }
 rect.background { fill: rgb(255,255,255); stroke: none; }
 
 text {
@@ -12,11 +14,15 @@ text {
 	font-size: 12.8px;
 	font-family: 'Roboto Medium', sans-serif;
 }
+text.annotation { fill: #8c959f; }
+text.notice { fill: #cf222e; }
 `
 
 const DarkStyles = `
 path { fill: none; stroke: rgb(51,51,51); }
 path.series { stroke: #6b63ff; }
+rect.series { fill: #6b63ff; stroke: none; }
+path.annotation { stroke: #8c959f; }
 rect.background { fill: rgb(255,255,255); stroke: none; }
 
 text {
@@ -26,16 +32,23 @@ text {
 	font-size: 12.8px;
 	font-family: 'Roboto Medium', sans-serif;
 }
+text.annotation { fill: #8c959f; }
+text.notice { fill: #cf222e; }
 
 path { stroke: rgb(230, 237, 243); }
 path.series { stroke: #6b63ff; }
 text { fill: rgb(230, 237, 243); }
 rect.background { fill: rgb(0,0,0); }
+path.annotation { stroke: #7d8590; }
+text.annotation { fill: #7d8590; }
+text.notice { fill: #f85149; }
 `
 
 const AdaptiveStyles = `
 path { fill: none; stroke: rgb(51,51,51); }
 path.series { stroke: #6b63ff; }
+rect.series { fill: #6b63ff; stroke: none; }
+path.annotation { stroke: #8c959f; }
 rect.background { fill: none; stroke: none; }
 
 text {
@@ -45,10 +58,15 @@ text {
 	font-size: 12.8px;
 	font-family: 'Roboto Medium', sans-serif;
 }
+text.annotation { fill: #8c959f; }
+text.notice { fill: #cf222e; }
 
 @media (prefers-color-scheme: dark) {
 	path { stroke: rgb(230, 237, 243); }
 	path.series { stroke: #6b63ff; }
 	text { fill: rgb(230, 237, 243); }
+	path.annotation { stroke: #7d8590; }
+	text.annotation { fill: #7d8590; }
+	text.notice { fill: #f85149; }
 }
 `
@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"github.com/golang/freetype/truetype"
 	"golang.org/x/image/font"
+	"math"
 	"strarcharts/internal/chart/svg"
+	"strconv"
 	"time"
 )
 
@@ -35,6 +37,38 @@ func intValueFormatter(v interface{}) string {
 	return fmt.Sprintf("%.0f", v)
 }
 
+// compactValueFormatter formats values with k/M/B suffixes, e.g. 1k or 2.5M.
+func compactValueFormatter(v interface{}) string {
+	typed, isTyped := v.(float64)
+	if !isTyped {
+		return ""
+	}
+
+	for _, unit := range []struct {
+		suffix string
+		size   float64
+	}{
+		{"B", 1e9},
+		{"M", 1e6},
+		{"k", 1e3},
+	} {
+		if math.Abs(typed) >= unit.size {
+			return strconv.FormatFloat(math.Round(typed/unit.size*10)/10, 'f', -1, 64) + unit.suffix
+		}
+	}
+	return strconv.FormatFloat(typed, 'f', 0, 64)
+}
+
+// truncateLabel shortens label to at most size runes, ending it with an
+// ellipsis when something was cut.
+func truncateLabel(label string, size int) string {
+	runes := []rune(label)
+	if len(runes) <= size {
+		return label
+	}
+	return string(runes[:size-1]) + "…"
+}
+
 func rotate(ang float32, x int, y int) string {
 	return fmt.Sprintf("rotate(%0.2f,%d,%d)", ang, x, y)
 }
@@ -0,0 +1,25 @@
+package chart
+
+// renderLegend draws one swatch and name per series in the top left corner of
+// the plot. Nothing is drawn for a single series, its axis name says it all.
+func (c *Chart) renderLegend(r Renderer, plot *Box) {
+	if len(c.Series) < 2 {
+		return
+	}
+
+	x := plot.Left + LegendMargin
+	y := plot.Top + LegendMargin
+	for i, series := range c.Series {
+		tb := measureText(series.Name, AxisFontSize)
+		y += tb.Height()
+
+		series.Color = c.seriesColor(i)
+		r.MoveTo(float64(x), float64(y-tb.Height()>>1))
+		r.LineTo(float64(x+LegendSwatchWidth), float64(y-tb.Height()>>1))
+		r.Stroke(series.Style())
+
+		r.Text(series.Name, x+LegendSwatchWidth+LegendMargin>>1, y, Style{})
+
+		y += LegendLineSpacing
+	}
+}
@@ -0,0 +1,27 @@
+package chart
+
+// renderNotice draws the chart notice in the top right corner of the plot,
+// opposite to the legend, over a background so it stays readable above the
+// series.
+func (c *Chart) renderNotice(r Renderer, plot *Box) {
+	if c.Notice == "" {
+		return
+	}
+
+	tb := measureText(c.Notice, AxisFontSize)
+	x := plot.Right - LegendMargin - tb.Width()
+	y := plot.Top + LegendMargin + tb.Height()
+
+	r.FillRect(Box{
+		Top:    y - tb.Height() - NoticePadding,
+		Left:   x - NoticePadding,
+		Right:  x + tb.Width() + NoticePadding,
+		Bottom: y + NoticePadding,
+	}, 2, Style{
+		ClassName: "background",
+		FillColor: c.Background,
+	})
+	r.Text(c.Notice, x, y, Style{
+		ClassName: "notice",
+	})
+}
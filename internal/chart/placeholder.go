@@ -0,0 +1,70 @@
+package chart
+
+import "io"
+
+// Placeholder stands in for a chart that is still being built, showing a
+// message and a progress bar.
+type Placeholder struct {
+	Message string
+	// Progress goes from 0 to 1, the bar is left out when it is negative.
+	Progress float64
+
+	Background string
+	Styles     string
+	Theme      Theme
+
+	Width  int
+	Height int
+}
+
+// Render writes the placeholder as SVG.
+func (p *Placeholder) Render(w io.Writer) {
+	cssStyles := p.Styles
+	if cssStyles == "" {
+		cssStyles = LightStyles
+	}
+
+	r := newSVGRenderer(p.Width, p.Height, cssStyles)
+	p.draw(r)
+	_ = r.Save(w)
+}
+
+// RenderPNG writes the placeholder as PNG, using Theme instead of Styles.
+func (p *Placeholder) RenderPNG(w io.Writer) error {
+	r := newRasterRenderer(p.Width, p.Height, p.Theme)
+	p.draw(r)
+	return r.Save(w)
+}
+
+func (p *Placeholder) draw(r Renderer) {
+	r.FillRect(Box{Right: p.Width, Bottom: p.Height}, 8, Style{
+		ClassName: "background",
+		FillColor: p.Background,
+	})
+
+	tb := measureText(p.Message, AxisFontSize)
+	x, y := (p.Width-tb.Width())>>1, p.Height>>1
+	r.Text(p.Message, x, y, Style{})
+	if p.Progress < 0 {
+		return
+	}
+
+	bar := Box{
+		Top:    y + PlaceholderBarMargin,
+		Left:   (p.Width - PlaceholderBarWidth) >> 1,
+		Right:  (p.Width + PlaceholderBarWidth) >> 1,
+		Bottom: y + PlaceholderBarMargin + PlaceholderBarHeight,
+	}
+	filled := bar
+	filled.Right = bar.Left + int(float64(bar.Width())*min(p.Progress, 1))
+	if filled.Right > filled.Left {
+		r.FillRect(filled, 2, Style{ClassName: "series"})
+	}
+
+	r.MoveTo(float64(bar.Left), float64(bar.Top))
+	r.LineTo(float64(bar.Right), float64(bar.Top))
+	r.LineTo(float64(bar.Right), float64(bar.Bottom))
+	r.LineTo(float64(bar.Left), float64(bar.Bottom))
+	r.LineTo(float64(bar.Left), float64(bar.Top))
+	r.Stroke(Style{StrokeWidth: 1})
+}
@@ -2,10 +2,19 @@ package chart
 
 import "math"
 
+// Scale is how values are mapped onto a Range domain.
+type Scale int
+
+const (
+	LinearScale Scale = iota
+	LogScale
+)
+
 type Range struct {
 	Min    float64
 	Max    float64
 	Domain int
+	Scale  Scale
 }
 
 func (r *Range) GetDelta() float64 {
@@ -13,8 +22,22 @@ func (r *Range) GetDelta() float64 {
 }
 
 func (r *Range) Translate(value float64) int {
+	if r.Scale == LogScale {
+		return r.translateLog(value)
+	}
+
 	normalized := value - r.Min
 	ratio := normalized / r.GetDelta()
 
 	return int(math.Ceil(ratio * float64(r.Domain)))
 }
+
+// translateLog maps value on a base 10 logarithmic scale, values below Min
+// are clamped to it as they can't be represented.
+func (r *Range) translateLog(value float64) int {
+	low := math.Log10(r.Min)
+	normalized := math.Log10(max(value, r.Min)) - low
+	ratio := normalized / (math.Log10(r.Max) - low)
+
+	return int(math.Ceil(ratio * float64(r.Domain)))
+}
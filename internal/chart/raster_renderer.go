@@ -0,0 +1,313 @@
+package chart
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/golang/freetype/raster"
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// rasterRenderer draws the chart into an RGBA image and encodes it as PNG.
+// Colors left empty in a Style are taken from the Theme, based on the class
+// name the SVG renderer would use to style the element.
+type rasterRenderer struct {
+	img   *image.RGBA
+	theme Theme
+	face  font.Face
+	path  [][]pointF
+}
+
+type pointF struct {
+	X, Y float64
+}
+
+func newRasterRenderer(width, height int, theme Theme) *rasterRenderer {
+	if theme == (Theme{}) {
+		theme = LightTheme
+	}
+	return &rasterRenderer{
+		img:   image.NewRGBA(image.Rect(0, 0, width, height)),
+		theme: theme,
+		face: truetype.NewFace(GetFont(), &truetype.Options{
+			DPI:     DPI,
+			Size:    AxisFontSize,
+			Hinting: font.HintingFull,
+		}),
+	}
+}
+
+func (r *rasterRenderer) MoveTo(x, y float64) {
+	r.path = append(r.path, []pointF{{x, y}})
+}
+
+func (r *rasterRenderer) LineTo(x, y float64) {
+	if len(r.path) == 0 {
+		r.MoveTo(x, y)
+		return
+	}
+	last := len(r.path) - 1
+	r.path[last] = append(r.path[last], pointF{x, y})
+}
+
+func (r *rasterRenderer) Stroke(style Style) {
+	subpaths := r.path
+	r.path = nil
+	if len(subpaths) == 0 {
+		return
+	}
+	if len(style.StrokeDashArray) > 0 {
+		subpaths = dashSubpaths(subpaths, style.StrokeDashArray)
+	}
+
+	var path raster.Path
+	for _, subpath := range subpaths {
+		path.Start(toFixedPoint(subpath[0].X, subpath[0].Y))
+		for _, point := range subpath[1:] {
+			path.Add1(toFixedPoint(point.X, point.Y))
+		}
+	}
+
+	col := r.strokeColor(style)
+	if col == nil {
+		return
+	}
+
+	bounds := r.img.Bounds()
+	rasterizer := raster.NewRasterizer(bounds.Dx(), bounds.Dy())
+	rasterizer.UseNonZeroWinding = true
+	width := fixed.Int26_6(max(MinStrokeWidth, style.StrokeWidth) * 64)
+	raster.Stroke(rasterizer, path, width, raster.ButtCapper, raster.BevelJoiner)
+	r.paint(rasterizer, col)
+}
+
+func (r *rasterRenderer) FillRect(box Box, radius float64, style Style) {
+	col := r.fillColor(style)
+	if col == nil {
+		return
+	}
+
+	left, top := float64(box.Left), float64(box.Top)
+	right, bottom := float64(box.Right), float64(box.Bottom)
+	radius = min(radius, float64(box.Width())/2, float64(box.Height())/2)
+
+	var path raster.Path
+	path.Start(toFixedPoint(left+radius, top))
+	path.Add1(toFixedPoint(right-radius, top))
+	path.Add2(toFixedPoint(right, top), toFixedPoint(right, top+radius))
+	path.Add1(toFixedPoint(right, bottom-radius))
+	path.Add2(toFixedPoint(right, bottom), toFixedPoint(right-radius, bottom))
+	path.Add1(toFixedPoint(left+radius, bottom))
+	path.Add2(toFixedPoint(left, bottom), toFixedPoint(left, bottom-radius))
+	path.Add1(toFixedPoint(left, top+radius))
+	path.Add2(toFixedPoint(left, top), toFixedPoint(left+radius, top))
+
+	bounds := r.img.Bounds()
+	rasterizer := raster.NewRasterizer(bounds.Dx(), bounds.Dy())
+	rasterizer.AddPath(path)
+	r.paint(rasterizer, col)
+}
+
+func (r *rasterRenderer) Text(body string, x, y int, style Style) {
+	col := r.fontColor(style)
+
+	// draw the text into a mask with its baseline origin at (0, ascent), so
+	// it can be rotated around that origin before being composed.
+	metrics := r.face.Metrics()
+	ascent := metrics.Ascent.Ceil()
+	width := font.MeasureString(r.face, body).Ceil()
+	mask := image.NewAlpha(image.Rect(0, 0, width, ascent+metrics.Descent.Ceil()))
+	drawer := &font.Drawer{
+		Dst:  mask,
+		Src:  image.Opaque,
+		Face: r.face,
+		Dot:  fixed.P(0, ascent),
+	}
+	drawer.DrawString(body)
+
+	origin := image.Pt(0, ascent)
+	rotated := rotateMask(mask, origin, style.TextRotation)
+	draw.DrawMask(
+		r.img,
+		rotated.Bounds().Sub(origin).Add(image.Pt(x, y)),
+		image.NewUniform(col),
+		image.Point{},
+		rotated,
+		rotated.Bounds().Min,
+		draw.Over,
+	)
+}
+
+func (r *rasterRenderer) Save(w io.Writer) error {
+	return png.Encode(w, r.img)
+}
+
+func (r *rasterRenderer) paint(rasterizer *raster.Rasterizer, col color.Color) {
+	painter := raster.NewRGBAPainter(r.img)
+	painter.SetColor(col)
+	rasterizer.Rasterize(painter)
+}
+
+func (r *rasterRenderer) strokeColor(style Style) color.Color {
+	if col := parseColor(style.StrokeColor); col != nil {
+		return col
+	}
+	switch style.ClassName {
+	case "series":
+		return parseColor(r.theme.Series)
+	case "annotation":
+		return parseColor(r.theme.Annotation)
+	}
+	return parseColor(r.theme.Axis)
+}
+
+func (r *rasterRenderer) fontColor(style Style) color.Color {
+	if col := parseColor(style.FontColor); col != nil {
+		return col
+	}
+	switch style.ClassName {
+	case "annotation":
+		return parseColor(r.theme.Annotation)
+	case "notice":
+		return parseColor(r.theme.Notice)
+	}
+	return parseColor(r.theme.Text)
+}
+
+func (r *rasterRenderer) fillColor(style Style) color.Color {
+	if col := parseColor(style.FillColor); col != nil {
+		return col
+	}
+	switch style.ClassName {
+	case "background":
+		return parseColor(r.theme.Background)
+	case "series":
+		return parseColor(r.theme.Series)
+	}
+	return parseColor(r.theme.Axis)
+}
+
+// dashSubpaths splits subpaths into the "on" parts of the dash pattern,
+// following the SVG stroke-dasharray semantics.
+func dashSubpaths(subpaths [][]pointF, pattern []float64) [][]pointF {
+	if sum(pattern...) <= 0 {
+		return subpaths
+	}
+	if len(pattern)%2 == 1 {
+		pattern = append(append([]float64{}, pattern...), pattern...)
+	}
+
+	var dashed [][]pointF
+	for _, subpath := range subpaths {
+		index, remaining, on := 0, pattern[0], true
+		current := []pointF{subpath[0]}
+		for i := 1; i < len(subpath); i++ {
+			from, to := subpath[i-1], subpath[i]
+			length := math.Hypot(to.X-from.X, to.Y-from.Y)
+			travelled := 0.0
+			for length-travelled > remaining {
+				travelled += remaining
+				ratio := travelled / length
+				point := pointF{from.X + (to.X-from.X)*ratio, from.Y + (to.Y-from.Y)*ratio}
+				if on {
+					dashed = append(dashed, append(current, point))
+					current = nil
+				} else {
+					current = []pointF{point}
+				}
+				on = !on
+				index = (index + 1) % len(pattern)
+				remaining = pattern[index]
+			}
+			remaining -= length - travelled
+			if on {
+				current = append(current, to)
+			}
+		}
+		if on && len(current) > 1 {
+			dashed = append(dashed, current)
+		}
+	}
+	return dashed
+}
+
+// rotateMask rotates mask clockwise by degrees around origin, keeping the
+// coordinate space of the original mask.
+func rotateMask(mask *image.Alpha, origin image.Point, degrees float64) *image.Alpha {
+	if degrees == 0 {
+		return mask
+	}
+
+	theta := degreesToRadians(degrees)
+	corners := mask.Bounds()
+	var bounds image.Rectangle
+	for i, corner := range []image.Point{
+		corners.Min,
+		{corners.Max.X, corners.Min.Y},
+		corners.Max,
+		{corners.Min.X, corners.Max.Y},
+	} {
+		x, y := rotateCoordinate(origin.X, origin.Y, corner.X, corner.Y, theta)
+		point := image.Rectangle{Min: image.Pt(x, y), Max: image.Pt(x+1, y+1)}
+		if i == 0 {
+			bounds = point
+		} else {
+			bounds = bounds.Union(point)
+		}
+	}
+
+	rotated := image.NewAlpha(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			sx, sy := rotateCoordinate(origin.X, origin.Y, x, y, -theta)
+			if image.Pt(sx, sy).In(corners) {
+				rotated.SetAlpha(x, y, mask.AlphaAt(sx, sy))
+			}
+		}
+	}
+	return rotated
+}
+
+func toFixedPoint(x, y float64) fixed.Point26_6 {
+	return fixed.Point26_6{
+		X: fixed.Int26_6(math.Round(x * 64)),
+		Y: fixed.Int26_6(math.Round(y * 64)),
+	}
+}
+
+// parseColor parses #rgb, #rrggbb and #rrggbbaa colors, returning nil for
+// anything else.
+func parseColor(value string) color.Color {
+	hex := strings.TrimPrefix(value, "#")
+	if len(hex) == len(value) {
+		return nil
+	}
+	if len(hex) == 3 {
+		hex = fmt.Sprintf("%c%c%c%c%c%c", hex[0], hex[0], hex[1], hex[1], hex[2], hex[2])
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	if len(hex) != 8 {
+		return nil
+	}
+	rgba, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return nil
+	}
+	return color.NRGBA{
+		R: uint8(rgba >> 24),
+		G: uint8(rgba >> 16),
+		B: uint8(rgba >> 8),
+		A: uint8(rgba),
+	}
+}
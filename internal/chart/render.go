@@ -3,10 +3,28 @@ package chart
 import (
 	"io"
 	"math"
-	"strarcharts/internal/chart/svg"
 )
 
+// Render writes the chart as SVG.
 func (c *Chart) Render(w io.Writer) {
+	cssStyles := c.Styles
+	if cssStyles == "" {
+		cssStyles = LightStyles
+	}
+
+	r := newSVGRenderer(c.Width, c.Height, cssStyles)
+	c.draw(r)
+	_ = r.Save(w)
+}
+
+// RenderPNG writes the chart as PNG, using Theme instead of Styles.
+func (c *Chart) RenderPNG(w io.Writer) error {
+	r := newRasterRenderer(c.Width, c.Height, c.Theme)
+	c.draw(r)
+	return r.Save(w)
+}
+
+func (c *Chart) draw(r Renderer) {
 	canvas := c.Box()
 
 	xRange, yRange := c.getRanges(canvas)
@@ -23,60 +41,66 @@ func (c *Chart) Render(w io.Writer) {
 	xRange.Domain = plot.Width()
 	yRange.Domain = plot.Height()
 
-	background := svg.Rect().
-		Attr("x", svg.Point(0)).
-		Attr("y", svg.Point(0)).
-		Attr("width", svg.Px(c.Width)).
-		Attr("height", svg.Px(c.Height)).
-		Attr("class", "background").
-		Attr("style", styles("fill", c.Background)).
-		Attr("rx", "8")
-
-	cssStyles := c.Styles
-	if cssStyles == "" {
-		cssStyles = LightStyles
+	r.FillRect(Box{Right: c.Width, Bottom: c.Height}, 8, Style{
+		ClassName: "background",
+		FillColor: c.Background,
+	})
+	for i := range c.Series {
+		series := c.Series[i]
+		series.Color = c.seriesColor(i)
+		series.Render(r, plot, xRange, yRange)
 	}
-
-	style := svg.Style().
-		Attr("type", "text/css").
-		Content(cssStyles)
-
-	svgElement := svg.SVG().
-		Attr("width", svg.Px(c.Width)).
-		Attr("height", svg.Px(c.Height)).
-		ContentFunc(func(w io.Writer) {
-			style.Render(w)
-			background.Render(w)
-			c.Series.Render(w, plot, xRange, yRange)
-			c.YAxis.Render(w, plot, yRange, yTicks)
-			c.XAxis.Render(w, plot, xRange, xTicks)
-		})
-
-	svgElement.Render(w)
+	c.XAxis.RenderAnnotations(r, plot, xRange, c.Annotations)
+	c.renderLegend(r, plot)
+	c.renderNotice(r, plot)
+	c.YAxis.Render(r, plot, yRange, yTicks)
+	c.XAxis.Render(r, plot, xRange, xTicks)
 }
 
 func (c *Chart) getRanges(canvas *Box) (*Range, *Range) {
 	minX, maxX := math.MaxFloat64, -math.MaxFloat64
 	minY, maxY := math.MaxFloat64, -math.MaxFloat64
 
-	seriesLength := c.Series.Len()
-	for index := 0; index < seriesLength; index++ {
-		vX, vY := c.Series.GetValues(index)
+	for _, series := range c.Series {
+		seriesLength := series.Len()
+		for index := 0; index < seriesLength; index++ {
+			vX, vY := series.GetValues(index)
 
-		minX = min(minX, vX)
-		maxX = max(maxX, vX)
+			minX = min(minX, vX)
+			maxX = max(maxX, vX)
 
-		minY = min(minY, vY)
-		maxY = max(maxY, vY)
-	}
+			minY = min(minY, vY)
+			maxY = max(maxY, vY)
+		}
 
-	delta := maxY - minY
-	roundTo := getRoundToForDelta(delta)
+		// bars grow from zero and the last one needs room to span.
+		if series.Type == BarSeries && seriesLength > 0 {
+			minY = min(minY, 0)
+			maxX = max(maxX, toFloat64(series.XValues[seriesLength-1])+series.barSpan(seriesLength-1))
+		}
+	}
 
-	yRange := &Range{
-		Min:    roundDown(minY, roundTo),
-		Max:    roundUp(maxY, roundTo),
-		Domain: canvas.Height(),
+	var yRange *Range
+	if c.YAxis.Scale == LogScale {
+		yRange = &Range{
+			Min:    math.Pow(10, math.Floor(math.Log10(max(minY, 1)))),
+			Max:    math.Pow(10, max(math.Ceil(math.Log10(max(maxY, 1))), 1)),
+			Domain: canvas.Height(),
+			Scale:  LogScale,
+		}
+	} else {
+		if maxY <= minY {
+			maxY = minY + 1
+		}
+
+		delta := maxY - minY
+		roundTo := getRoundToForDelta(delta)
+
+		yRange = &Range{
+			Min:    roundDown(minY, roundTo),
+			Max:    roundUp(maxY, roundTo),
+			Domain: canvas.Height(),
+		}
 	}
 
 	xRange := &Range{
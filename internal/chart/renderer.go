@@ -0,0 +1,32 @@
+package chart
+
+import "io"
+
+// Style describes how a primitive is drawn. The SVG renderer leaves empty
+// colors to the chart CSS through ClassName, the raster renderer resolves
+// them through the chart Theme.
+type Style struct {
+	ClassName       string
+	StrokeColor     string
+	StrokeWidth     float64
+	StrokeDashArray []float64
+	FillColor       string
+	FontColor       string
+	TextRotation    float64
+}
+
+// Renderer draws chart primitives into a specific output format.
+type Renderer interface {
+	// MoveTo starts a new sub path at the given point.
+	MoveTo(x, y float64)
+	// LineTo adds a line from the current point to the given point.
+	LineTo(x, y float64)
+	// Stroke draws the current path and resets it.
+	Stroke(style Style)
+	// FillRect fills the box, rounding its corners by radius.
+	FillRect(box Box, radius float64, style Style)
+	// Text draws body with its baseline starting at x, y.
+	Text(body string, x, y int, style Style)
+	// Save writes the rendered output.
+	Save(w io.Writer) error
+}
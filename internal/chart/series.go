@@ -1,16 +1,27 @@
 package chart
 
 import (
-	"io"
-	"strarcharts/internal/chart/svg"
 	"time"
 )
 
+// SeriesType is how a series is drawn.
+type SeriesType int
+
+const (
+	LineSeries SeriesType = iota
+	BarSeries
+)
+
 type Series struct {
+	Type        SeriesType
+	Name        string
 	XValues     []time.Time
 	YValues     []float64
 	StrokeWidth float64
 	Color       string
+
+	// StrokeDashArray dashes the line, e.g. to mark it as an estimate.
+	StrokeDashArray []float64
 }
 
 func (ts *Series) Len() int {
@@ -29,11 +40,16 @@ func (ts *Series) GetLastValues() (x, y float64) {
 	return
 }
 
-func (ts *Series) Render(w io.Writer, canvasBox *Box, xrange, yrange *Range) {
+func (ts *Series) Render(r Renderer, canvasBox *Box, xrange, yrange *Range) {
 	if len(ts.XValues) == 0 {
 		return
 	}
 
+	if ts.Type == BarSeries {
+		ts.renderBars(r, canvasBox, xrange, yrange)
+		return
+	}
+
 	cb := canvasBox.Bottom
 	cl := canvasBox.Left
 
@@ -44,18 +60,24 @@ func (ts *Series) Render(w io.Writer, canvasBox *Box, xrange, yrange *Range) {
 	var vx, vy float64
 	var x, y int
 
-	path := svg.Path().
-		Attr("stroke-width", normaliseStrokeWidth(ts.StrokeWidth)).
-		Attr("style", styles("stroke", ts.Color)).
-		Attr("class", "series").
-		MoveTo(x0, y0)
+	r.MoveTo(float64(x0), float64(y0))
 
 	for i := 1; i < ts.Len(); i++ {
 		vx, vy = ts.GetValues(i)
 		x = cl + xrange.Translate(vx)
 		y = cb - yrange.Translate(vy)
-		path.LineTo(x, y)
+		r.LineTo(float64(x), float64(y))
 	}
 
-	path.Render(w)
+	r.Stroke(ts.Style())
+}
+
+// Style is the style used to stroke the series line.
+func (ts *Series) Style() Style {
+	return Style{
+		ClassName:       "series",
+		StrokeWidth:     ts.StrokeWidth,
+		StrokeColor:     ts.Color,
+		StrokeDashArray: ts.StrokeDashArray,
+	}
 }
@@ -0,0 +1,115 @@
+package chart
+
+import (
+	"html"
+	"io"
+	"math"
+	"strarcharts/internal/chart/svg"
+	"strings"
+)
+
+type svgRenderer struct {
+	width   int
+	height  int
+	styles  string
+	content strings.Builder
+	path    *svg.PathBuilder
+}
+
+func newSVGRenderer(width, height int, styles string) *svgRenderer {
+	return &svgRenderer{
+		width:  width,
+		height: height,
+		styles: styles,
+	}
+}
+
+func (r *svgRenderer) MoveTo(x, y float64) {
+	if r.path == nil {
+		r.path = svg.Path()
+	}
+	if isWhole(x) && isWhole(y) {
+		r.path.MoveTo(int(x), int(y))
+		return
+	}
+	r.path.MoveToF(x, y)
+}
+
+func (r *svgRenderer) LineTo(x, y float64) {
+	if r.path == nil {
+		r.path = svg.Path()
+	}
+	if isWhole(x) && isWhole(y) {
+		r.path.LineTo(int(x), int(y))
+		return
+	}
+	r.path.LineToF(x, y)
+}
+
+func (r *svgRenderer) Stroke(style Style) {
+	if r.path == nil {
+		return
+	}
+	r.path.
+		Attr("stroke-width", normaliseStrokeWidth(style.StrokeWidth)).
+		Attr("style", styles("stroke", style.StrokeColor)).
+		Attr("stroke-dasharray", dashArray(style.StrokeDashArray)).
+		Attr("class", style.ClassName).
+		Render(&r.content)
+	r.path = nil
+}
+
+func (r *svgRenderer) FillRect(box Box, radius float64, style Style) {
+	rect := svg.Rect().
+		Attr("x", svg.Point(box.Left)).
+		Attr("y", svg.Point(box.Top)).
+		Attr("width", svg.Px(box.Width())).
+		Attr("height", svg.Px(box.Height())).
+		Attr("class", style.ClassName).
+		Attr("style", styles("fill", style.FillColor))
+	if radius > 0 {
+		rect.Attr("rx", svg.Point(radius))
+	}
+	rect.Render(&r.content)
+}
+
+func (r *svgRenderer) Text(body string, x, y int, style Style) {
+	text := svg.Text().
+		Content(html.EscapeString(body)).
+		Attr("class", style.ClassName).
+		Attr("style", styles("fill", style.FontColor)).
+		Attr("x", svg.Point(x)).
+		Attr("y", svg.Point(y))
+	if style.TextRotation != 0 {
+		text.Attr("transform", rotate(float32(style.TextRotation), x, y))
+	}
+	text.Render(&r.content)
+}
+
+func (r *svgRenderer) Save(w io.Writer) error {
+	style := svg.Style().
+		Attr("type", "text/css").
+		Content(r.styles)
+
+	svg.SVG().
+		Attr("width", svg.Px(r.width)).
+		Attr("height", svg.Px(r.height)).
+		ContentFunc(func(w io.Writer) {
+			style.Render(w)
+			_, _ = io.WriteString(w, r.content.String())
+		}).
+		Render(w)
+	return nil
+}
+
+func dashArray(values []float64) string {
+	dashes := make([]string, 0, len(values))
+	for _, value := range values {
+		dashes = append(dashes, svg.Point(value))
+	}
+	return strings.Join(dashes, " ")
+}
+
+func isWhole(value float64) bool {
+	return value == math.Trunc(value)
+}
@@ -0,0 +1,29 @@
+package chart
+
+// Theme holds the colors used by renderers that can't apply the CSS styles.
+type Theme struct {
+	Background string
+	Axis       string
+	Text       string
+	Series     string
+	Annotation string
+	Notice     string
+}
+
+var LightTheme = Theme{
+	Background: "#ffffff",
+	Axis:       "#333333",
+	Text:       "#333333",
+	Series:     "#6b63ff",
+	Annotation: "#8c959f",
+	Notice:     "#cf222e",
+}
+
+var DarkTheme = Theme{
+	Background: "#000000",
+	Axis:       "#e6edf3",
+	Text:       "#e6edf3",
+	Series:     "#6b63ff",
+	Annotation: "#7d8590",
+	Notice:     "#f85149",
+}
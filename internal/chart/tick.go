@@ -22,6 +22,10 @@ func (t Ticks) String() string {
 }
 
 func generateTicks(rng *Range, isVertical bool, formatter ValueFormatter) []Tick {
+	if rng.Scale == LogScale {
+		return generateLogTicks(rng)
+	}
+
 	ticks := []Tick{
 		{Value: rng.Min, Label: formatter(rng.Min)},
 	}
@@ -57,3 +61,16 @@ func generateTicks(rng *Range, isVertical bool, formatter ValueFormatter) []Tick
 		Label: formatter(rng.Max),
 	})
 }
+
+// generateLogTicks places a tick on every power of 10 in the range, labeled
+// as 1, 10, 100, 1k and so on.
+func generateLogTicks(rng *Range) []Tick {
+	var ticks []Tick
+	for value := rng.Min; value <= rng.Max && len(ticks) < DefaultTickCountSanityCheck; value *= 10 {
+		ticks = append(ticks, Tick{
+			Value: value,
+			Label: compactValueFormatter(value),
+		})
+	}
+	return ticks
+}
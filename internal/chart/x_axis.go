@@ -1,9 +1,8 @@
 package chart
 
 import (
-	"io"
 	"math"
-	"strarcharts/internal/chart/svg"
+	"sort"
 )
 
 type XAxis struct {
@@ -41,17 +40,16 @@ func (xa *XAxis) Measure(canvas *Box, ra *Range, ticks []Tick) *Box {
 	}
 }
 
-func (xa *XAxis) Render(w io.Writer, canvasBox *Box, ra *Range, ticks []Tick) {
-	strokeWidth := normaliseStrokeWidth(xa.StrokeWidth)
-	strokeStyle := styles("stroke", xa.Color)
-	fillStyle := styles("fill", xa.Color)
+func (xa *XAxis) Render(r Renderer, canvasBox *Box, ra *Range, ticks []Tick) {
+	strokeStyle := Style{
+		StrokeWidth: xa.StrokeWidth,
+		StrokeColor: xa.Color,
+	}
+	fontStyle := Style{FontColor: xa.Color}
 
-	svg.Path().
-		Attr("stroke-width", strokeWidth).
-		Attr("style", strokeStyle).
-		MoveToF(float64(canvasBox.Left)-xa.StrokeWidth/2, float64(canvasBox.Bottom)).
-		LineTo(canvasBox.Right, canvasBox.Bottom).
-		Render(w)
+	r.MoveTo(float64(canvasBox.Left)-xa.StrokeWidth/2, float64(canvasBox.Bottom))
+	r.LineTo(float64(canvasBox.Right), float64(canvasBox.Bottom))
+	r.Stroke(strokeStyle)
 
 	var tx, ty int
 	var maxTextHeight int
@@ -61,24 +59,16 @@ func (xa *XAxis) Render(w io.Writer, canvasBox *Box, ra *Range, ticks []Tick) {
 
 		tx = canvasBox.Left + lx
 
-		svg.Path().
-			Attr("stroke-width", strokeWidth).
-			Attr("style", strokeStyle).
-			MoveTo(tx, canvasBox.Bottom).
-			LineTo(tx, canvasBox.Bottom+VerticalTickHeight).
-			Render(w)
+		r.MoveTo(float64(tx), float64(canvasBox.Bottom))
+		r.LineTo(float64(tx), float64(canvasBox.Bottom+VerticalTickHeight))
+		r.Stroke(strokeStyle)
 
 		tb := measureText(t.Label, AxisFontSize)
 
 		tx = tx - tb.Width()>>1
 		ty = canvasBox.Bottom + XAxisMargin + tb.Height()
 
-		svg.Text().
-			Content(t.Label).
-			Attr("style", fillStyle).
-			Attr("x", svg.Point(tx)).
-			Attr("y", svg.Point(ty)).
-			Render(w)
+		r.Text(t.Label, tx, ty, fontStyle)
 
 		maxTextHeight = max(maxTextHeight, tb.Height())
 	}
@@ -87,10 +77,57 @@ func (xa *XAxis) Render(w io.Writer, canvasBox *Box, ra *Range, ticks []Tick) {
 	tx = canvasBox.Right - (canvasBox.Width()>>1 + tb.Width()>>1)
 	ty = canvasBox.Bottom + XAxisMargin + maxTextHeight + XAxisMargin + tb.Height()
 
-	svg.Text().
-		Content(xa.Name).
-		Attr("style", fillStyle).
-		Attr("x", svg.Point(tx)).
-		Attr("y", svg.Point(ty)).
-		Render(w)
+	r.Text(xa.Name, tx, ty, fontStyle)
+}
+
+// RenderAnnotations draws a dashed vertical line for each annotation within
+// the range, labeled at the top of the plot so labels never run into the tick
+// labels below the axis. Labels are stacked in up to MaxAnnotationRows rows,
+// and dropped when they would overlap in all of them.
+func (xa *XAxis) RenderAnnotations(r Renderer, canvasBox *Box, ra *Range, annotations []Annotation) {
+	lineStyle := Style{
+		ClassName:       "annotation",
+		StrokeWidth:     MinStrokeWidth,
+		StrokeDashArray: AnnotationDashArray,
+	}
+	fontStyle := Style{ClassName: "annotation"}
+
+	annotations = append([]Annotation(nil), annotations...)
+	sort.SliceStable(annotations, func(i, j int) bool {
+		return annotations[i].X.Before(annotations[j].X)
+	})
+
+	rowsRight := make([]int, MaxAnnotationRows)
+	for i := range rowsRight {
+		rowsRight[i] = math.MinInt32
+	}
+
+	for _, annotation := range annotations {
+		v := toFloat64(annotation.X)
+		if v < ra.Min || v > ra.Max {
+			continue
+		}
+
+		lx := canvasBox.Left + ra.Translate(v)
+		r.MoveTo(float64(lx), float64(canvasBox.Bottom))
+		r.LineTo(float64(lx), float64(canvasBox.Top))
+		r.Stroke(lineStyle)
+
+		label := truncateLabel(annotation.Label, MaxAnnotationLabelLen)
+		tb := measureText(label, AxisFontSize)
+		tx := lx + AnnotationLabelPadding
+		if tx+tb.Width() > canvasBox.Right {
+			tx = lx - AnnotationLabelPadding - tb.Width()
+		}
+
+		for row, right := range rowsRight {
+			if tx <= right+AnnotationLabelPadding {
+				continue
+			}
+			ty := canvasBox.Top + (row+1)*tb.Height() + row*AnnotationLabelPadding
+			r.Text(label, tx, ty, fontStyle)
+			rowsRight[row] = tx + tb.Width()
+			break
+		}
+	}
 }
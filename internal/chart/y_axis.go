@@ -1,15 +1,14 @@
 package chart
 
 import (
-	"io"
 	"math"
-	"strarcharts/internal/chart/svg"
 )
 
 type YAxis struct {
 	Name        string
 	StrokeWidth float64
 	Color       string
+	Scale       Scale
 }
 
 func (ya *YAxis) Measure(canvas *Box, ra *Range, ticks []Tick) *Box {
@@ -41,20 +40,18 @@ func (ya *YAxis) Measure(canvas *Box, ra *Range, ticks []Tick) *Box {
 	}
 }
 
-func (ya *YAxis) Render(w io.Writer, canvasBox *Box, ra *Range, ticks []Tick) {
+func (ya *YAxis) Render(r Renderer, canvasBox *Box, ra *Range, ticks []Tick) {
 	lx := canvasBox.Right
 	tx := lx + YAxisMargin
-	strokeStyle := styles("stroke", ya.Color)
-	fillStyle := styles("fill", ya.Color)
-
-	strokeWidth := normaliseStrokeWidth(ya.StrokeWidth)
+	strokeStyle := Style{
+		StrokeWidth: ya.StrokeWidth,
+		StrokeColor: ya.Color,
+	}
+	fontStyle := Style{FontColor: ya.Color}
 
-	svg.Path().
-		Attr("stroke-width", strokeWidth).
-		Attr("style", strokeStyle).
-		MoveTo(lx, canvasBox.Bottom).
-		LineToF(float64(lx), float64(canvasBox.Top)-ya.StrokeWidth/2).
-		Render(w)
+	r.MoveTo(float64(lx), float64(canvasBox.Bottom))
+	r.LineTo(float64(lx), float64(canvasBox.Top)-ya.StrokeWidth/2)
+	r.Stroke(strokeStyle)
 
 	var maxTextWidth int
 	var finalTextY int
@@ -68,30 +65,17 @@ func (ya *YAxis) Render(w io.Writer, canvasBox *Box, ra *Range, ticks []Tick) {
 
 		finalTextY = ly + tb.Height()>>1
 
-		svg.Path().
-			Attr("stroke-width", strokeWidth).
-			Attr("style", strokeStyle).
-			MoveTo(lx, ly).
-			LineTo(lx+HorizontalTickWidth, ly).
-			Render(w)
-
-		svg.Text().
-			Content(t.Label).
-			Attr("style", fillStyle).
-			Attr("x", svg.Point(tx)).
-			Attr("y", svg.Point(finalTextY)).
-			Render(w)
+		r.MoveTo(float64(lx), float64(ly))
+		r.LineTo(float64(lx+HorizontalTickWidth), float64(ly))
+		r.Stroke(strokeStyle)
+
+		r.Text(t.Label, tx, finalTextY, fontStyle)
 	}
 
 	tb := measureText(ya.Name, AxisFontSize)
 	tx = canvasBox.Right + YAxisMargin + maxTextWidth + YAxisMargin
 	ty := canvasBox.Top + (canvasBox.Height()>>1 - tb.Height()>>1)
 
-	svg.Text().
-		Content(ya.Name).
-		Attr("x", svg.Point(tx)).
-		Attr("y", svg.Point(ty)).
-		Attr("style", fillStyle).
-		Attr("transform", rotate(90, tx, ty)).
-		Render(w)
+	fontStyle.TextRotation = 90
+	r.Text(ya.Name, tx, ty, fontStyle)
 }
@@ -0,0 +1,139 @@
+// Package coalesce shares the result of in-flight work between the callers
+// asking for it at the same time.
+package coalesce
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/singleflight"
+	"sync"
+)
+
+var shared = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "starcharts",
+	Subsystem: "coalesce",
+	Name:      "shared_results_total",
+	Help:      "callers that got a result shared with other callers",
+}, []string{"group"})
+
+func init() {
+	prometheus.MustRegister(shared)
+}
+
+type classKey struct{}
+
+type abandonedKey struct{}
+
+// WithClass returns a context whose work is only shared with callers of the
+// same class, e.g. so low priority work never holds back callers that don't
+// share its priority.
+func WithClass(ctx context.Context, class string) context.Context {
+	return context.WithValue(ctx, classKey{}, class)
+}
+
+// Abandoned returns a channel closed once every caller waiting for the work
+// of ctx gave up, e.g. so the work doesn't wait for something nobody needs
+// anymore. It is nil outside of Do, which never closes.
+func Abandoned(ctx context.Context) <-chan struct{} {
+	abandoned, _ := ctx.Value(abandonedKey{}).(chan struct{})
+	return abandoned
+}
+
+// Group coalesces work by key.
+type Group[T any] struct {
+	name  string
+	group singleflight.Group
+
+	lock    sync.Mutex
+	flights map[string]*flight
+}
+
+// flight counts the callers waiting for the work of a key.
+type flight struct {
+	callers   int
+	abandoned chan struct{}
+}
+
+// New creates a group, its name labels the metrics.
+func New[T any](name string) *Group[T] {
+	return &Group[T]{name: name, flights: map[string]*flight{}}
+}
+
+// Do runs fn once for all the concurrent callers with the same key and gives
+// each of them its result, which must not be modified. The work doesn't stop
+// when a caller gives up, fn gets a context that is never canceled, but the
+// caller returns as soon as its own ctx is done. Callers of different classes
+// don't share work. fn can tell when every caller gave up with Abandoned, a
+// caller whose own work was abandoned gives up too, so abandonment goes
+// through nested groups.
+func (g *Group[T]) Do(ctx context.Context, key string, fn func(ctx context.Context) (T, error)) (T, error) {
+	if class, ok := ctx.Value(classKey{}).(string); ok {
+		key = class + "/" + key
+	}
+	f := g.join(key)
+	ch := g.group.DoChan(key, func() (any, error) {
+		defer g.land(key, f)
+		return fn(context.WithValue(context.WithoutCancel(ctx), abandonedKey{}, f.abandoned))
+	})
+
+	var zero T
+	select {
+	case <-ctx.Done():
+		g.leave(key, f, true)
+		return zero, ctx.Err()
+	case <-Abandoned(ctx):
+		g.leave(key, f, true)
+		return zero, context.Canceled
+	case res := <-ch:
+		g.leave(key, f, false)
+		if res.Shared {
+			shared.WithLabelValues(g.name).Inc()
+		}
+		return res.Val.(T), res.Err
+	}
+}
+
+// join counts a caller in the flight of key, starting one if needed.
+func (g *Group[T]) join(key string) *flight {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	f, ok := g.flights[key]
+	if !ok {
+		f = &flight{abandoned: make(chan struct{})}
+		g.flights[key] = f
+	}
+	f.callers++
+	return f
+}
+
+// leave counts a caller out of the flight, which is abandoned once the last
+// caller gave up on it. The work of an abandoned flight is forgotten, so the
+// next callers don't get what it returns once abandoned.
+func (g *Group[T]) leave(key string, f *flight, gaveUp bool) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	f.callers--
+	if f.callers == 0 && gaveUp {
+		close(f.abandoned)
+		if g.flights[key] == f {
+			delete(g.flights, key)
+			g.group.Forget(key)
+		}
+	}
+}
+
+// land ends the flight once its work is done, the next callers start another
+// one.
+func (g *Group[T]) land(key string, f *flight) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.forget(key, f)
+}
+
+// forget drops the flight of key, unless another one took its place. Must be
+// called with the lock held.
+func (g *Group[T]) forget(key string, f *flight) {
+	if g.flights[key] == f {
+		delete(g.flights, key)
+	}
+}
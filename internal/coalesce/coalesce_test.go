@@ -0,0 +1,156 @@
+package coalesce
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDoShares(t *testing.T) {
+	g := New[int]("test")
+	release := make(chan struct{})
+	var runs atomic.Int32
+	fn := func(ctx context.Context) (int, error) {
+		runs.Add(1)
+		<-release
+		return 42, nil
+	}
+
+	var wg sync.WaitGroup
+	for _, ctx := range []context.Context{
+		context.Background(),
+		context.Background(),
+		WithClass(context.Background(), "background"),
+	} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if n, err := g.Do(ctx, "key", fn); n != 42 || err != nil {
+				t.Errorf("expected 42, got %d, %v", n, err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	// one run per class.
+	if n := runs.Load(); n != 2 {
+		t.Fatalf("expected 2 runs, got %d", n)
+	}
+}
+
+func TestDoAbandoned(t *testing.T) {
+	g := New[int]("test")
+	started := make(chan struct{})
+	abandoned := make(chan error, 1)
+	fn := func(ctx context.Context) (int, error) {
+		close(started)
+		select {
+		case <-Abandoned(ctx):
+			abandoned <- ctx.Err()
+		case <-time.After(time.Second):
+			abandoned <- errors.New("not abandoned")
+		}
+		return 0, nil
+	}
+
+	first, cancelFirst := context.WithCancel(context.Background())
+	second, cancelSecond := context.WithCancel(context.Background())
+	done := make(chan struct{}, 2)
+	go func() {
+		_, _ = g.Do(first, "key", fn)
+		done <- struct{}{}
+	}()
+	<-started
+	go func() {
+		_, _ = g.Do(second, "key", fn)
+		done <- struct{}{}
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	cancelFirst()
+	<-done
+	select {
+	case err := <-abandoned:
+		t.Fatalf("abandoned while a caller still waits: %v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	cancelSecond()
+	<-done
+	// the work itself is never canceled, it can only tell it was abandoned.
+	if err := <-abandoned; err != nil {
+		t.Fatalf("expected the work to be abandoned, got %v", err)
+	}
+}
+
+func TestAbandonedOutsideDo(t *testing.T) {
+	if Abandoned(context.Background()) != nil {
+		t.Fatal("expected no channel outside of Do")
+	}
+}
+
+func TestDoAbandonedNested(t *testing.T) {
+	outer, inner := New[int]("outer"), New[int]("inner")
+	started := make(chan struct{})
+	abandoned := make(chan struct{})
+	innerFn := func(ctx context.Context) (int, error) {
+		close(started)
+		select {
+		case <-Abandoned(ctx):
+			close(abandoned)
+		case <-time.After(time.Second):
+		}
+		return 0, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		_, _ = outer.Do(ctx, "key", func(ctx context.Context) (int, error) {
+			return inner.Do(ctx, "key", innerFn)
+		})
+	}()
+	<-started
+	cancel()
+
+	select {
+	case <-abandoned:
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("expected the inner work to be abandoned with the outer one")
+	}
+}
+
+func TestDoAfterAbandoned(t *testing.T) {
+	g := New[int]("test")
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var runs atomic.Int32
+	fn := func(ctx context.Context) (int, error) {
+		if runs.Add(1) == 1 {
+			close(started)
+			// the abandoned work still runs for a while.
+			<-Abandoned(ctx)
+			<-release
+			return 0, context.Canceled
+		}
+		return 42, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		_, _ = g.Do(ctx, "key", fn)
+	}()
+	<-started
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	time.AfterFunc(100*time.Millisecond, func() { close(release) })
+
+	// new callers don't get the result of the abandoned work.
+	if n, err := g.Do(context.Background(), "key", fn); n != 42 || err != nil {
+		t.Fatalf("expected 42, got %d, %v", n, err)
+	}
+}
@@ -0,0 +1,143 @@
+package github
+
+import (
+	"context"
+	"errors"
+	log2 "github.com/apex/log"
+	"golang.org/x/sync/errgroup"
+	"sort"
+	"strarcharts/internal/timeline"
+	"sync"
+	"time"
+)
+
+// maxListedStars is how many stargazers GitHub lists, whatever the page size.
+const maxListedStars = 40000
+
+const (
+	// estimateEdgePages is how many pages are sampled at each end of the
+	// listing.
+	estimateEdgePages = 3
+	// estimateSpreadPages is how many pages are sampled in between.
+	estimateSpreadPages = 10
+)
+
+// EstimateResult holds the estimated cumulative stars of a repository, along
+// with how many sample pages were requested and how many of them failed.
+type EstimateResult struct {
+	Points        []timeline.Point
+	Samples       int
+	FailedSamples int
+}
+
+// Complete reports whether every sample page was fetched.
+func (r EstimateResult) Complete() bool {
+	return r.FailedSamples == 0
+}
+
+// EstimatedStargazers approximates the star history of a repository too big to
+// be listed entirely. It samples the first pages, a spread of pages in between
+// and the last listed ones, and anchors the curve with no stars at the
+// repository creation and with StargazersCount now, so everything after the
+// listing cap is interpolated. Like Stargazers, sample pages that fail are
+// counted in the result, an error is only returned when they all failed.
+// Points hold the cumulative count of stars and are shared with concurrent
+// callers.
+func (gh *GitHub) EstimatedStargazers(ctx context.Context, repo Repository) (EstimateResult, error) {
+	return gh.estimateFlights.Do(ctx, repo.FullName, func(ctx context.Context) (EstimateResult, error) {
+		lock := "estimate/" + repo.FullName
+		release, fresh, err := gh.lockFetch(ctx, lock)
+		if err != nil {
+			return EstimateResult{}, err
+		}
+		defer release()
+		result, err := gh.estimatedStargazers(ctx, repo, fresh)
+		if err == nil && result.Complete() {
+			gh.markFetched(lock)
+		}
+		return result, err
+	})
+}
+
+func (gh *GitHub) estimatedStargazers(ctx context.Context, repo Repository, fresh bool) (EstimateResult, error) {
+	var (
+		wg     errgroup.Group
+		lock   sync.Mutex
+		points []timeline.Point
+		errs   []error
+	)
+
+	samples := estimateSamplePages(gh.maxPages())
+	gh.progress.start(repo.FullName, 0, len(samples))
+	defer gh.progress.finish(repo.FullName)
+
+	wg.SetLimit(4)
+	for _, page := range samples {
+		page := page
+		wg.Go(func() error {
+			stars, err := gh.starGazersPage(ctx, repo, page, fresh)
+			gh.progress.advance(repo.FullName)
+			lock.Lock()
+			defer lock.Unlock()
+			if errors.Is(err, errNoMorePages) {
+				return nil
+			}
+			if err != nil {
+				log2.WithField("repo", repo.FullName).WithField("page", page).WithError(err).Warn("failed to get sample page")
+				errs = append(errs, err)
+				return nil
+			}
+			for i, star := range stars {
+				points = append(points, timeline.Point{
+					Time:  star.StarredAt,
+					Count: (page-1)*gh.pageSize + i + 1,
+				})
+			}
+			return nil
+		})
+	}
+	_ = wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return EstimateResult{}, err
+	}
+	if len(points) == 0 && len(errs) > 0 {
+		return EstimateResult{}, errs[0]
+	}
+
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].Count < points[j].Count
+	})
+	if createdAt, err := time.Parse(time.RFC3339, repo.CreatedAt); err == nil &&
+		(len(points) == 0 || createdAt.Before(points[0].Time)) {
+		points = append([]timeline.Point{{Time: createdAt}}, points...)
+	}
+	if len(points) == 0 || repo.StargazersCount > points[len(points)-1].Count {
+		points = append(points, timeline.Point{Time: time.Now(), Count: repo.StargazersCount})
+	}
+	return EstimateResult{Points: points, Samples: len(samples), FailedSamples: len(errs)}, nil
+}
+
+// estimateSamplePages returns the sorted pages to sample out of maxPages.
+func estimateSamplePages(maxPages int) []int {
+	seen := map[int]bool{}
+	var pages []int
+	add := func(page int) {
+		if page < 1 || page > maxPages || seen[page] {
+			return
+		}
+		seen[page] = true
+		pages = append(pages, page)
+	}
+
+	for i := 0; i < estimateEdgePages; i++ {
+		add(1 + i)
+		add(maxPages - i)
+	}
+	for i := 1; i <= estimateSpreadPages; i++ {
+		add(i * maxPages / (estimateSpreadPages + 1))
+	}
+
+	sort.Ints(pages)
+	return pages
+}
@@ -0,0 +1,60 @@
+package github
+
+import (
+	"context"
+	"net/http/httptest"
+	"strarcharts/config"
+	"strarcharts/internal/cache"
+	"testing"
+	"time"
+)
+
+func TestEstimatedStargazersFailedSamples(t *testing.T) {
+	samples := estimateSamplePages(maxListedStars / 100)
+	allFailed := map[int]bool{}
+	for _, page := range samples {
+		allFailed[page] = true
+	}
+
+	for name, tt := range map[string]struct {
+		failed     map[int]bool
+		wantFailed int
+		wantErr    bool
+	}{
+		"every sample":      {},
+		"some samples fail": {failed: map[int]bool{1: true, samples[len(samples)-1]: true}, wantFailed: 2},
+		"all samples fail":  {failed: allFailed, wantErr: true},
+		"pages not sampled": {failed: map[int]bool{4: true}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			fake := &fakeStargazers{count: maxListedStars, failed: tt.failed}
+			srv := httptest.NewServer(fake)
+			defer srv.Close()
+			gh := New(config.Config{
+				GithubApiUrl:            srv.URL,
+				GithubStargazersFetcher: FetcherREST,
+				GithubPageSize:          100,
+				GithubTimeout:           time.Second,
+				CacheStargazersTTL:      time.Hour,
+			}, cache.NewMemory(0, 0, 0))
+
+			repo := Repository{FullName: "o/r", StargazersCount: 2 * maxListedStars}
+			result, err := gh.EstimatedStargazers(context.Background(), repo)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Samples != len(samples) || result.FailedSamples != tt.wantFailed {
+				t.Fatalf("expected %d of %d samples failed, got %d of %d", tt.wantFailed, len(samples), result.FailedSamples, result.Samples)
+			}
+			if result.Complete() != (tt.wantFailed == 0) {
+				t.Fatalf("expected complete %v", tt.wantFailed == 0)
+			}
+		})
+	}
+}
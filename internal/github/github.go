@@ -1,16 +1,17 @@
 package github
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/apex/log"
 	"github.com/prometheus/client_golang/prometheus"
-	"io"
 	"net/http"
 	"strarcharts/config"
 	"strarcharts/internal/cache"
+	"strarcharts/internal/coalesce"
 	"strarcharts/internal/roundrobin"
+	"strings"
+	"time"
 )
 
 var ErrRateLimit = errors.New("rate limited, please try again later")
@@ -18,10 +19,25 @@ var ErrRateLimit = errors.New("rate limited, please try again later")
 var errGitHubAPI = errors.New("failed to talk with github api")
 
 type GitHub struct {
+	client          *http.Client
+	apiURL          string
+	graphqlURL      string
 	tokens          roundrobin.RoundRobiner
+	fetcher         string
 	pageSize        int
-	cache           *cache.Redis
+	cache           cache.Cache
 	maxRateUsagePct int
+	backgroundPct   int
+	repoTTL         time.Duration
+	stargazersTTL   time.Duration
+	fetchLockTTL    time.Duration
+	progress        progressRegistry
+	priority        priorityGate
+
+	repoFlights     *coalesce.Group[Repository]
+	releaseFlights  *coalesce.Group[[]Release]
+	starsFlights    *coalesce.Group[StargazersResult]
+	estimateFlights *coalesce.Group[EstimateResult]
 }
 
 var rateLimits = prometheus.NewCounter(prometheus.CounterOpts{
@@ -58,13 +74,50 @@ func init() {
 	prometheus.MustRegister(rateLimits, effectiveEtags, invalidatedTokens, tokensCount, rateLimiters)
 }
 
-func New(config config.Config, cache *cache.Redis) *GitHub {
+func New(config config.Config, cache cache.Cache) *GitHub {
+	switch config.GithubStargazersFetcher {
+	case FetcherREST, FetcherGraphQL:
+	default:
+		log.Fatalf("invalid github_stargazers_fetcher: %s", config.GithubStargazersFetcher)
+	}
+
 	tokensCount.Set(float64(len(config.GithubTokens)))
-	return &GitHub{
-		tokens:   roundrobin.New(config.GithubTokens),
-		pageSize: config.GithubPageSize,
-		cache:    cache,
+	apiURL := strings.TrimSuffix(config.GithubApiUrl, "/")
+	gh := &GitHub{
+		client: &http.Client{
+			Transport: newTransport(config.GithubTimeout, config.GithubMaxRetries, config.GithubMaxRetryAfter),
+		},
+		apiURL:          apiURL,
+		graphqlURL:      graphqlURL(apiURL, config.GithubGraphqlUrl),
+		fetcher:         config.GithubStargazersFetcher,
+		pageSize:        config.GithubPageSize,
+		cache:           cache,
+		maxRateUsagePct: config.GitHubMaxRateUsagePct,
+		backgroundPct:   config.GithubBackgroundRateUsagePct,
+		repoTTL:         config.CacheRepoTTL,
+		stargazersTTL:   config.CacheStargazersTTL,
+		fetchLockTTL:    config.GithubFetchLockTTL,
+		repoFlights:     coalesce.New[Repository]("repo"),
+		releaseFlights:  coalesce.New[[]Release]("releases"),
+		starsFlights:    coalesce.New[StargazersResult]("stargazers"),
+		estimateFlights: coalesce.New[EstimateResult]("estimate"),
 	}
+	gh.tokens = roundrobin.New(config.GithubTokens, gh.validateToken, config.GithubTokenRevalidateInterval)
+	return gh
+}
+
+// graphqlURL returns the configured GraphQL endpoint, or derives it from
+// the REST API URL: https://api.github.com/graphql for github.com and
+// https://HOST/api/graphql for GitHub Enterprise Server, whose REST API
+// lives at https://HOST/api/v3.
+func graphqlURL(apiURL, configured string) string {
+	if configured != "" {
+		return strings.TrimSuffix(configured, "/")
+	}
+	if strings.HasSuffix(apiURL, "/api/v3") {
+		return strings.TrimSuffix(apiURL, "/v3") + "/graphql"
+	}
+	return apiURL + "/graphql"
 }
 
 const maxTries = 3
@@ -73,78 +126,77 @@ func (gh *GitHub) authorizedDo(req *http.Request, try int) (*http.Response, erro
 	if try > maxTries {
 		return nil, fmt.Errorf("couldn't find a valid token")
 	}
+	if try == 0 {
+		leave, err := gh.priority.enter(req.Context())
+		if err != nil {
+			return nil, err
+		}
+		defer leave()
+	}
 
-	token, err := gh.tokens.Pick()
+	token, err := gh.pickToken(req.Context())
+	if errors.Is(err, ErrBackgroundQuota) {
+		return nil, err
+	}
 	if err != nil || token == nil {
 		log.WithError(err).Error("couldn't get a valid token")
-		return http.DefaultClient.Do(req)
+		return gh.client.Do(req)
 	}
 
 	if err := gh.checkToken(token); err != nil {
 		log.WithError(err).Error("couldn't check rate limit, trying again")
 		return gh.authorizedDo(req, try+1)
 	}
-
-	req.Header.Add("Authorization", fmt.Sprintf("token %s", token.Key()))
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return resp, err
-	}
-	return resp, err
-}
-
-func (gh *GitHub) checkToken(token *roundrobin.Token) error {
-	req, err := http.NewRequest(http.MethodGet, "https://api.github.com/rate_limit", nil)
-	if err != nil {
-		return err
+	if isBackground(req.Context()) && !gh.withinBackgroundShare(token) {
+		// its quota was only just probed, another token may be under the
+		// background share.
+		return gh.authorizedDo(req, try+1)
 	}
 
-	req.Header.Add("Authorization", fmt.Sprintf("token %s", token.Key()))
-	resp, err := http.DefaultClient.Do(req)
+	req.Header.Set("Authorization", fmt.Sprintf("token %s", token.Key()))
+	resp, err := gh.client.Do(req)
 	if err != nil {
-		return err
+		return resp, err
 	}
-
-	defer resp.Body.Close()
-
+	// retries send a copy of the request, with its body, if any, rewound.
 	if resp.StatusCode == http.StatusUnauthorized {
-		token.Invalidate()
-		invalidatedTokens.Inc()
-		return fmt.Errorf("token is invalid")
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("request failed with status %d", resp.StatusCode)
-	}
-
-	bts, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return err
+		gh.invalidate(token)
+		retry, ok := rewind(req)
+		if !ok {
+			return resp, err
+		}
+		resp.Body.Close()
+		return gh.authorizedDo(retry, try+1)
 	}
-
-	var limit rateLimit
-	if err := json.Unmarshal(bts, &limit); err != nil {
-		return err
-	}
-
-	rate := limit.Rate
-	log.Debugf("%s rate %d/%d", token, rate.Remaining, rate.Limit)
-	rateLimiters.WithLabelValues(token.String()).Set(float64(rate.Remaining))
-	if isAboveTargetUsage(rate, gh.maxRateUsagePct) {
-		return fmt.Errorf("token usage is too high: %d/%d", rate.Remaining, rate.Limit)
+	gh.trackRate(token, resp)
+	// the token quota ran out, it is parked now so another one gets picked.
+	exhausted := resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests
+	if exhausted && resp.Header.Get("X-RateLimit-Remaining") == "0" && try < maxTries {
+		if retry, ok := rewind(req); ok {
+			resp.Body.Close()
+			return gh.authorizedDo(retry, try+1)
+		}
 	}
-	return nil
-}
-
-func isAboveTargetUsage(rate rate, target int) bool {
-	return rate.Remaining*100/rate.Limit < target
+	return resp, err
 }
 
-type rateLimit struct {
-	Rate rate `json:"rate"`
+// cachedEtag returns the ETag cached at key, or an empty one if there is
+// none, so the request is made unconditionally.
+func (gh *GitHub) cachedEtag(key string) string {
+	var etag string
+	if err := gh.cache.Get(key, &etag); err != nil {
+		if !errors.Is(err, cache.ErrCacheMiss) {
+			log.WithError(err).Warnf("failed to get %s from cache", key)
+		}
+		return ""
+	}
+	return etag
 }
 
-type rate struct {
-	Remaining int `json:"remaining"`
-	Limit     int `json:"limit"`
+// deleteEtag drops an ETag whose payload is no longer cached, so it isn't
+// sent again.
+func (gh *GitHub) deleteEtag(key string) {
+	if err := gh.cache.Delete(key); err != nil {
+		log.WithError(err).Warnf("failed to delete %s from cache", key)
+	}
 }
@@ -0,0 +1,224 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	log2 "github.com/apex/log"
+	"io"
+	"net/http"
+	"sort"
+	"strarcharts/internal/cache"
+	"strings"
+	"time"
+)
+
+// Stargazer fetchers selectable with GITHUB_STARGAZERS_FETCHER.
+const (
+	FetcherREST    = "rest"
+	FetcherGraphQL = "graphql"
+)
+
+// maxGraphqlPageSize is the most nodes a GraphQL connection returns at once.
+const maxGraphqlPageSize = 100
+
+const stargazersQuery = `query($owner: String!, $name: String!, $first: Int!, $after: String) {
+  repository(owner: $owner, name: $name) {
+    stargazers(first: $first, after: $after, orderBy: {field: STARRED_AT, direction: ASC}) {
+      pageInfo { endCursor hasNextPage }
+      edges { starredAt node { login databaseId } }
+    }
+  }
+}`
+
+// cursorPage is a page of the GraphQL stargazers connection.
+type cursorPage struct {
+	Stargazers  []Stargazer
+	EndCursor   string
+	HasNextPage bool
+}
+
+type graphqlRequest struct {
+	Query     string         `json:"query"`
+	Variables map[string]any `json:"variables"`
+}
+
+type graphqlError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+type stargazersResponse struct {
+	Data struct {
+		Repository *struct {
+			Stargazers struct {
+				PageInfo struct {
+					EndCursor   string `json:"endCursor"`
+					HasNextPage bool   `json:"hasNextPage"`
+				} `json:"pageInfo"`
+				Edges []struct {
+					StarredAt time.Time `json:"starredAt"`
+					Node      struct {
+						Login      string `json:"login"`
+						DatabaseID int64  `json:"databaseId"`
+					} `json:"node"`
+				} `json:"edges"`
+			} `json:"stargazers"`
+		} `json:"repository"`
+	} `json:"data"`
+	Errors []graphqlError `json:"errors"`
+}
+
+// graphqlStargazers walks the stargazers connection with cursors, which
+// doesn't depend on StargazersCount being accurate. Pages are sequential, so
+// a failing page ends the listing and is reported as the only failed one.
+func (gh *GitHub) graphqlStargazers(ctx context.Context, repo Repository) (StargazersResult, error) {
+	var result StargazersResult
+	gh.progress.start(repo.FullName, 0, gh.lastPage(repo))
+	defer gh.progress.finish(repo.FullName)
+
+	cursor := ""
+	for page := 1; ; page++ {
+		stars, err := gh.getStargazersCursorPage(ctx, repo, cursor)
+		gh.progress.advance(repo.FullName)
+		if err != nil {
+			if page == 1 || ctx.Err() != nil {
+				return result, err
+			}
+			log2.WithField("repo", repo.FullName).WithField("page", page).WithError(err).Warn("failed to get page")
+			result.FailedPages = append(result.FailedPages, PageError{Page: page, Err: err})
+			break
+		}
+
+		result.FetchedPages = append(result.FetchedPages, page)
+		result.Stargazers = append(result.Stargazers, stars.Stargazers...)
+		if !stars.HasNextPage {
+			break
+		}
+		cursor = stars.EndCursor
+	}
+
+	sort.SliceStable(result.Stargazers, func(i, j int) bool {
+		return result.Stargazers[i].StarredAt.Before(result.Stargazers[j].StarredAt)
+	})
+	return result, nil
+}
+
+// getStargazersCursorPage gets the page of stargazers after cursor. Pages
+// followed by another one only change when stars are removed, so they are
+// cached by cursor for as long as the repository details, while the last
+// page is always fetched again.
+func (gh *GitHub) getStargazersCursorPage(ctx context.Context, repo Repository, cursor string) (cursorPage, error) {
+	log := log2.WithField("repo", repo.FullName).WithField("cursor", cursor)
+	defer log.Trace("get cursor page").Stop(nil)
+	var page cursorPage
+	key := fmt.Sprintf("%s_graphql_%s", repo.FullName, cursor)
+
+	if err := gh.cache.Get(key, &page); err == nil {
+		log.Debug("using cached page")
+		return page, nil
+	} else if !errors.Is(err, cache.ErrCacheMiss) {
+		log.WithError(err).Warnf("failed to get %s from cache", key)
+	}
+
+	resp, err := gh.makeStargazersQuery(ctx, repo, cursor)
+	if err != nil {
+		return page, err
+	}
+	defer resp.Body.Close()
+
+	bts, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return page, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusForbidden, http.StatusTooManyRequests:
+		err := forbiddenError(resp, bts)
+		log.WithError(err).Warn("request forbidden")
+		return page, err
+	case http.StatusOK:
+	default:
+		return page, fmt.Errorf("%w: %v", errGitHubAPI, string(bts))
+	}
+
+	var result stargazersResponse
+	if err := json.Unmarshal(bts, &result); err != nil {
+		return page, err
+	}
+	if err := queryError(result.Errors); err != nil {
+		return page, err
+	}
+	if result.Data.Repository == nil {
+		return page, ErrorNotFound
+	}
+
+	connection := result.Data.Repository.Stargazers
+	for _, edge := range connection.Edges {
+		page.Stargazers = append(page.Stargazers, Stargazer{
+			StarredAt: edge.StarredAt,
+			User: User{
+				Login: edge.Node.Login,
+				ID:    edge.Node.DatabaseID,
+			},
+		})
+	}
+	page.EndCursor = connection.PageInfo.EndCursor
+	page.HasNextPage = connection.PageInfo.HasNextPage
+
+	if page.HasNextPage {
+		if err := gh.cache.Put(key, page, gh.repoTTL); err != nil {
+			log.WithError(err).Warnf("failed to cache %s", key)
+		}
+	}
+	return page, nil
+}
+
+// queryError turns the errors of a GraphQL response into an error.
+func queryError(errs []graphqlError) error {
+	if len(errs) == 0 {
+		return nil
+	}
+
+	messages := make([]string, 0, len(errs))
+	for _, err := range errs {
+		switch err.Type {
+		case "RATE_LIMITED":
+			rateLimits.Inc()
+			return ErrRateLimit
+		case "NOT_FOUND":
+			return ErrorNotFound
+		}
+		messages = append(messages, err.Message)
+	}
+	return fmt.Errorf("%w: %s", errGitHubAPI, strings.Join(messages, "; "))
+}
+
+func (gh *GitHub) makeStargazersQuery(ctx context.Context, repo Repository, cursor string) (*http.Response, error) {
+	owner, name, _ := strings.Cut(repo.FullName, "/")
+	variables := map[string]any{
+		"owner": owner,
+		"name":  name,
+		"first": min(gh.pageSize, maxGraphqlPageSize),
+	}
+	if cursor != "" {
+		variables["after"] = cursor
+	}
+
+	body, err := json.Marshal(graphqlRequest{
+		Query:     stargazersQuery,
+		Variables: variables,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, gh.graphqlURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	return gh.authorizedDo(req, 0)
+}
@@ -0,0 +1,122 @@
+package github
+
+import (
+	"context"
+	"errors"
+	log2 "github.com/apex/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"strarcharts/internal/cache"
+	"strarcharts/internal/coalesce"
+	"time"
+)
+
+// lockPollInterval is how often a locked fetch is retried.
+const lockPollInterval = 250 * time.Millisecond
+
+var lockWaits = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "starcharts",
+	Subsystem: "github",
+	Name:      "fetch_lock_waits_total",
+	Help:      "fetches that waited for another instance to finish the same one",
+})
+
+func init() {
+	prometheus.MustRegister(lockWaits)
+}
+
+// lockFetch makes sure only one instance sharing the cache fetches name at a
+// time. It waits for the instance holding the lock to finish, and keeps
+// refreshing the lock until the returned release is called. The lock expires
+// after fetchLockTTL if its holder crashed, a zero TTL disables locking. A
+// cache failing to lock doesn't prevent the fetch. Waiting stops as soon as
+// ctx is done or every caller sharing the fetch gave up.
+//
+// fresh reports whether the instance waited for completed the same fetch,
+// see markFetched, so what it cached can be used without asking GitHub.
+func (gh *GitHub) lockFetch(ctx context.Context, name string) (release func(), fresh bool, err error) {
+	if gh.fetchLockTTL <= 0 {
+		return func() {}, false, nil
+	}
+
+	log := log2.WithField("lock", name)
+	key := "lock/" + name
+
+	var (
+		lock   cache.Lock
+		before time.Time
+	)
+	for waited := false; ; waited = true {
+		lock, err = gh.cache.Lock(key, gh.fetchLockTTL)
+		if err == nil {
+			if waited {
+				fresh = !gh.fetchedAt(name).Equal(before)
+			}
+			break
+		}
+		if !errors.Is(err, cache.ErrLockHeld) {
+			log.WithError(err).Warn("failed to lock, fetching anyway")
+			return func() {}, false, nil
+		}
+		if !waited {
+			log.Info("waiting for another instance")
+			lockWaits.Inc()
+			before = gh.fetchedAt(name)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		case <-coalesce.Abandoned(ctx):
+			return nil, false, context.Canceled
+		case <-time.After(lockPollInterval):
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(gh.fetchLockTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := lock.Refresh(gh.fetchLockTTL); err != nil {
+					log.WithError(err).Warn("failed to refresh lock")
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		if err := lock.Release(); err != nil {
+			log.WithError(err).Warn("failed to release lock")
+		}
+	}, fresh, nil
+}
+
+func fetchedKey(name string) string {
+	return "fetched/" + name
+}
+
+// markFetched tells the instances waiting for the lock of name that the
+// fetch completed, and cached everything it got. It must be called before
+// releasing the lock.
+func (gh *GitHub) markFetched(name string) {
+	if gh.fetchLockTTL <= 0 {
+		return
+	}
+	// only compared for equality, clocks of instances don't need to agree.
+	if err := gh.cache.Put(fetchedKey(name), time.Now(), gh.fetchLockTTL); err != nil {
+		log2.WithError(err).WithField("lock", name).Warn("failed to mark fetch as done")
+	}
+}
+
+// fetchedAt returns when the fetch of name last completed, if recently.
+func (gh *GitHub) fetchedAt(name string) time.Time {
+	var at time.Time
+	if err := gh.cache.Get(fetchedKey(name), &at); err != nil && !errors.Is(err, cache.ErrCacheMiss) {
+		log2.WithError(err).WithField("lock", name).Warn("failed to get when fetch was done")
+	}
+	return at
+}
@@ -0,0 +1,101 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"strarcharts/internal/coalesce"
+	"strarcharts/internal/roundrobin"
+	"sync/atomic"
+	"time"
+)
+
+// ErrBackgroundQuota is returned to background requests once the tokens
+// used their background share of the quota, the rest is kept for live ones.
+var ErrBackgroundQuota = errors.New("background share of the rate limit used up")
+
+// backgroundPollInterval is how often background requests check whether the
+// live requests they wait for are done.
+const backgroundPollInterval = 100 * time.Millisecond
+
+var backgroundDeferrals = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "starcharts",
+	Subsystem: "github",
+	Name:      "background_deferrals_total",
+	Help:      "background requests that waited for live ones or were refused, by reason",
+}, []string{"reason"})
+
+func init() {
+	prometheus.MustRegister(backgroundDeferrals)
+}
+
+type backgroundKey struct{}
+
+// Background marks the requests made with the returned context as background
+// work, e.g. refreshing repositories nobody asked for yet. They wait for the
+// live requests in flight to be done, and fail with ErrBackgroundQuota
+// instead of using more than the background share of the quota of a token.
+// Background work is never shared with live callers, whose work would be
+// held back otherwise.
+func Background(ctx context.Context) context.Context {
+	ctx = coalesce.WithClass(ctx, "background")
+	return context.WithValue(ctx, backgroundKey{}, true)
+}
+
+func isBackground(ctx context.Context) bool {
+	background, _ := ctx.Value(backgroundKey{}).(bool)
+	return background
+}
+
+// priorityGate holds background requests back while live ones are in flight.
+type priorityGate struct {
+	live atomic.Int64
+}
+
+// enter lets the request through, waiting for the live requests in flight to
+// be done first if it is a background one. leave must be called once the
+// request is done.
+func (g *priorityGate) enter(ctx context.Context) (leave func(), err error) {
+	if !isBackground(ctx) {
+		g.live.Add(1)
+		return func() { g.live.Add(-1) }, nil
+	}
+	if g.live.Load() == 0 {
+		return func() {}, nil
+	}
+
+	backgroundDeferrals.WithLabelValues("live").Inc()
+	ticker := time.NewTicker(backgroundPollInterval)
+	defer ticker.Stop()
+	for g.live.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return func() {}, nil
+}
+
+// pickToken picks the token for a request. Background requests only get the
+// tokens that used less than the background share of their quota, and fail
+// with ErrBackgroundQuota when there is none. Tokens over the share aren't
+// parked, live requests can still use them.
+func (gh *GitHub) pickToken(ctx context.Context) (*roundrobin.Token, error) {
+	if !isBackground(ctx) {
+		return gh.tokens.Pick()
+	}
+	token, err := gh.tokens.PickWhere(gh.withinBackgroundShare)
+	if errors.Is(err, roundrobin.ErrNoAcceptedToken) {
+		backgroundDeferrals.WithLabelValues("quota").Inc()
+		return nil, ErrBackgroundQuota
+	}
+	return token, err
+}
+
+// withinBackgroundShare reports whether background requests may use the
+// token, whose quota might still be unknown.
+func (gh *GitHub) withinBackgroundShare(token *roundrobin.Token) bool {
+	rate, ok := token.Rate()
+	return !ok || !isAboveTargetUsage(rate, gh.backgroundPct)
+}
@@ -0,0 +1,126 @@
+package github
+
+import (
+	"strings"
+	"sync"
+)
+
+// Progress is how far the fetch of a repository's stargazers got, in pages.
+type Progress struct {
+	Fetched int  `json:"fetched"`
+	Total   int  `json:"total"`
+	Done    bool `json:"done"`
+}
+
+// progressRegistry tracks the stargazer fetches in flight in this process, by
+// case insensitive repository name, and sends their progress to subscribers.
+type progressRegistry struct {
+	lock        sync.Mutex
+	repos       map[string]Progress
+	subscribers map[string]map[chan Progress]struct{}
+}
+
+func (p *progressRegistry) start(name string, fetched, total int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.repos == nil {
+		p.repos = map[string]Progress{}
+	}
+	key := strings.ToLower(name)
+	p.repos[key] = Progress{Fetched: fetched, Total: total}
+	p.notify(key, p.repos[key])
+}
+
+func (p *progressRegistry) advance(name string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	key := strings.ToLower(name)
+	progress, ok := p.repos[key]
+	if !ok {
+		return
+	}
+	progress.Fetched++
+	// the total is only a guess for cursor pagination.
+	progress.Total = max(progress.Total, progress.Fetched)
+	p.repos[key] = progress
+	p.notify(key, progress)
+}
+
+func (p *progressRegistry) finish(name string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	key := strings.ToLower(name)
+	progress := p.repos[key]
+	delete(p.repos, key)
+
+	progress.Done = true
+	p.notify(key, progress)
+}
+
+// notify sends the progress to the subscribers of key without blocking, a
+// subscriber lagging behind only gets the latest progress.
+func (p *progressRegistry) notify(key string, progress Progress) {
+	for ch := range p.subscribers[key] {
+		select {
+		case ch <- progress:
+		default:
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- progress
+		}
+	}
+}
+
+// Subscribe sends the progress of the repository's stargazer fetches to the
+// returned channel, until cancel is called. The last progress of a fetch is
+// Done.
+func (gh *GitHub) Subscribe(name string) (progress <-chan Progress, cancel func()) {
+	p := &gh.progress
+	key := strings.ToLower(name)
+	ch := make(chan Progress, 1)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.subscribers == nil {
+		p.subscribers = map[string]map[chan Progress]struct{}{}
+	}
+	if p.subscribers[key] == nil {
+		p.subscribers[key] = map[chan Progress]struct{}{}
+	}
+	p.subscribers[key][ch] = struct{}{}
+
+	return ch, func() {
+		p.lock.Lock()
+		defer p.lock.Unlock()
+		delete(p.subscribers[key], ch)
+		if len(p.subscribers[key]) == 0 {
+			delete(p.subscribers, key)
+		}
+	}
+}
+
+// Progress tells how far the fetch of the repository's stargazers got, if
+// one is in flight.
+func (gh *GitHub) Progress(name string) (Progress, bool) {
+	gh.progress.lock.Lock()
+	defer gh.progress.lock.Unlock()
+	progress, ok := gh.progress.repos[strings.ToLower(name)]
+	return progress, ok
+}
+
+// PendingPages is how many pages fetching the repository's stargazers would
+// take, leaving out the pages a previous sync already has.
+func (gh *GitHub) PendingPages(repo Repository) int {
+	if gh.fetcher == FetcherGraphQL {
+		return gh.lastPage(repo)
+	}
+	if gh.lastPage(repo) > gh.maxPages() {
+		return len(estimateSamplePages(gh.maxPages()))
+	}
+	if state, ok := gh.syncState(repo); ok && state.FullPages < gh.lastPage(repo) {
+		return gh.lastPage(repo) - state.FullPages
+	}
+	return gh.lastPage(repo)
+}
@@ -0,0 +1,174 @@
+package github
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/apex/log"
+	"io"
+	"net/http"
+	"strarcharts/internal/roundrobin"
+	"strconv"
+	"time"
+)
+
+var errInvalidToken = errors.New("token is invalid")
+
+// checkToken fails if the token already used more than the target share of
+// its quota. The quota is tracked from the headers of previous responses, the
+// /rate_limit endpoint is only called when it is still unknown.
+func (gh *GitHub) checkToken(token *roundrobin.Token) error {
+	rate, ok := token.Rate()
+	if !ok {
+		var err error
+		if rate, err = gh.probeRateLimit(token); err != nil {
+			if errors.Is(err, errInvalidToken) {
+				gh.invalidate(token)
+			}
+			return err
+		}
+	}
+
+	log.Debugf("%s rate %d/%d", token, rate.Remaining, rate.Limit)
+	if isAboveTargetUsage(rate, gh.maxRateUsagePct) {
+		token.Park(rate.Reset)
+		return fmt.Errorf("token usage is too high: %d/%d", rate.Remaining, rate.Limit)
+	}
+	return nil
+}
+
+// validateToken checks whether a revoked token works again.
+func (gh *GitHub) validateToken(token *roundrobin.Token) error {
+	if _, err := gh.probeRateLimit(token); err != nil {
+		return err
+	}
+	invalidatedTokens.Dec()
+	return nil
+}
+
+// invalidate takes the token out of rotation. Concurrent requests may all
+// find it revoked, it is only counted once.
+func (gh *GitHub) invalidate(token *roundrobin.Token) {
+	if token.Invalidate() {
+		invalidatedTokens.Inc()
+	}
+}
+
+// probeRateLimit gets the quota of the token from the /rate_limit endpoint.
+// GitHub Enterprise Server answers 404 there when rate limiting is disabled,
+// the token is then unlimited, as it is when the quota reported is empty.
+func (gh *GitHub) probeRateLimit(token *roundrobin.Token) (roundrobin.Rate, error) {
+	var rate roundrobin.Rate
+	req, err := http.NewRequest(http.MethodGet, gh.apiURL+"/rate_limit", nil)
+	if err != nil {
+		return rate, err
+	}
+
+	req.Header.Add("Authorization", fmt.Sprintf("token %s", token.Key()))
+	resp, err := gh.client.Do(req)
+	if err != nil {
+		return rate, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return rate, errInvalidToken
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		rate = roundrobin.Rate{Unlimited: true}
+		gh.setRate(token, rate)
+		return rate, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return rate, fmt.Errorf("request failed with status %d", resp.StatusCode)
+	}
+
+	bts, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return rate, err
+	}
+
+	var limit rateLimit
+	if err := json.Unmarshal(bts, &limit); err != nil {
+		return rate, err
+	}
+
+	rate = roundrobin.Rate{
+		Remaining: limit.Rate.Remaining,
+		Limit:     limit.Rate.Limit,
+		Reset:     time.Unix(limit.Rate.Reset, 0),
+		Unlimited: limit.Rate.Limit == 0,
+	}
+	gh.setRate(token, rate)
+	return rate, nil
+}
+
+// trackRate records the quota reported by the X-RateLimit-* headers of a
+// response made with the token. Only the core quota is tracked, the GraphQL
+// one is separate and doesn't tell how many REST requests are left, but a
+// token that used it up is parked until it resets so the next request picks
+// another one.
+func (gh *GitHub) trackRate(token *roundrobin.Token, resp *http.Response) {
+	rate, ok := rateFromHeaders(resp.Header)
+	if !ok {
+		return
+	}
+	if resource := resp.Header.Get("X-RateLimit-Resource"); resource != "" && resource != "core" {
+		if rate.Remaining == 0 {
+			token.Park(rate.Reset)
+		}
+		return
+	}
+	gh.setRate(token, rate)
+}
+
+func (gh *GitHub) setRate(token *roundrobin.Token, rate roundrobin.Rate) {
+	token.SetRate(rate)
+	if rate.Unlimited {
+		rateLimiters.DeleteLabelValues(token.ID())
+		return
+	}
+	rateLimiters.WithLabelValues(token.ID()).Set(float64(rate.Remaining))
+}
+
+func rateFromHeaders(header http.Header) (roundrobin.Rate, bool) {
+	remaining, err := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return roundrobin.Rate{}, false
+	}
+	limit, err := strconv.Atoi(header.Get("X-RateLimit-Limit"))
+	if err != nil || limit == 0 {
+		return roundrobin.Rate{}, false
+	}
+	reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return roundrobin.Rate{}, false
+	}
+	return roundrobin.Rate{
+		Remaining: remaining,
+		Limit:     limit,
+		Reset:     time.Unix(reset, 0),
+	}, true
+}
+
+// isAboveTargetUsage reports whether more than target percent of the quota
+// was used.
+func isAboveTargetUsage(rate roundrobin.Rate, target int) bool {
+	if rate.Limit == 0 {
+		return false
+	}
+	return (rate.Limit-rate.Remaining)*100/rate.Limit > target
+}
+
+type rateLimit struct {
+	Rate rate `json:"rate"`
+}
+
+type rate struct {
+	Remaining int   `json:"remaining"`
+	Limit     int   `json:"limit"`
+	Reset     int64 `json:"reset"`
+}
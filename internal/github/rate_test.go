@@ -0,0 +1,33 @@
+package github
+
+import (
+	dto "github.com/prometheus/client_model/go"
+	"strarcharts/config"
+	"strarcharts/internal/cache"
+	"strarcharts/internal/roundrobin"
+	"testing"
+)
+
+func TestInvalidateCountsOnce(t *testing.T) {
+	gh := New(config.Config{GithubStargazersFetcher: FetcherREST}, cache.NewMemory(0, 0, 0))
+	token := roundrobin.NewToken("token")
+	before := gaugeValue(t)
+
+	// concurrent requests may all find the token revoked.
+	gh.invalidate(token)
+	gh.invalidate(token)
+	if n := gaugeValue(t) - before; n != 1 {
+		t.Fatalf("expected 1 more invalidated token, got %v", n)
+	}
+	if token.Valid() {
+		t.Fatal("expected the token to be invalid")
+	}
+}
+
+func gaugeValue(t *testing.T) float64 {
+	var metric dto.Metric
+	if err := invalidatedTokens.Write(&metric); err != nil {
+		t.Fatal(err)
+	}
+	return metric.GetGauge().GetValue()
+}
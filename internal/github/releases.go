@@ -0,0 +1,106 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	log2 "github.com/apex/log"
+	"io"
+	"net/http"
+	"time"
+)
+
+type Release struct {
+	TagName     string    `json:"tag_name"`
+	Name        string    `json:"name"`
+	Draft       bool      `json:"draft"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+// Releases returns the latest published releases of the repository. Plain
+// tags are left out, as GitHub doesn't tell when they were created.
+func (gh *GitHub) Releases(ctx context.Context, name string) ([]Release, error) {
+	return gh.releaseFlights.Do(ctx, name, func(ctx context.Context) ([]Release, error) {
+		return gh.releases(ctx, name, true)
+	})
+}
+
+func (gh *GitHub) releases(ctx context.Context, name string, useEtag bool) ([]Release, error) {
+	var releases []Release
+	log := log2.WithField("repo", name)
+	key := name + "_releases"
+	etagKey := key + "_etag"
+
+	var etag string
+	if useEtag {
+		etag = gh.cachedEtag(etagKey)
+	}
+
+	resp, err := gh.makeReleasesRequest(ctx, name, etag)
+	if err != nil {
+		return releases, err
+	}
+	defer resp.Body.Close()
+	bts, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return releases, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		log.Info("not modified")
+		effectiveEtags.Inc()
+		if err := gh.cache.Get(key, &releases); err != nil {
+			log.WithError(err).Warnf("failed to get %s from cache, refetching", key)
+			gh.deleteEtag(etagKey)
+			return gh.releases(ctx, name, false)
+		}
+		return releases, nil
+
+	case http.StatusForbidden, http.StatusTooManyRequests:
+		err := forbiddenError(resp, bts)
+		log.WithError(err).Warn("request forbidden")
+		return releases, err
+
+	case http.StatusOK:
+		var all []Release
+		if err := json.Unmarshal(bts, &all); err != nil {
+			return releases, err
+		}
+		for _, release := range all {
+			if !release.Draft && !release.PublishedAt.IsZero() {
+				releases = append(releases, release)
+			}
+		}
+		if err := gh.cache.Put(key, releases, gh.repoTTL); err != nil {
+			log.WithError(err).Warnf("failed to cache %s", key)
+		}
+
+		etag = resp.Header.Get("etag")
+		if etag != "" {
+			if err := gh.cache.Put(etagKey, etag, gh.repoTTL); err != nil {
+				log.WithError(err).Warnf("failed to cache %s", etagKey)
+			}
+		}
+		return releases, nil
+	case http.StatusNotFound:
+		return releases, ErrorNotFound
+	default:
+		return releases, fmt.Errorf("%w: %v", errGitHubAPI, string(bts))
+	}
+}
+
+func (gh *GitHub) makeReleasesRequest(ctx context.Context, name, etag string) (*http.Response, error) {
+	url := fmt.Sprintf("%s/repos/%s/releases?per_page=100", gh.apiURL, name)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if etag != "" {
+		req.Header.Add("If-None-Match", etag)
+	}
+
+	return gh.authorizedDo(req, 0)
+}
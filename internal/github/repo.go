@@ -18,56 +18,62 @@ type Repository struct {
 
 var ErrorNotFound = errors.New("Repository not found")
 
+// RepoDetails gets the repository, sharing the request with concurrent callers.
 func (gh *GitHub) RepoDetails(ctx context.Context, name string) (Repository, error) {
+	return gh.repoFlights.Do(ctx, name, func(ctx context.Context) (Repository, error) {
+		return gh.repoDetails(ctx, name, true)
+	})
+}
+
+// repoDetails gets the repository, sending the cached ETag if useEtag is set
+// so GitHub can answer with a cheap 304 served from the cache.
+func (gh *GitHub) repoDetails(ctx context.Context, name string, useEtag bool) (Repository, error) {
 	var repo Repository
 	log := log2.WithField("repo", name)
-	var etag string
 	etagKey := name + "_etag"
 
-	if err := gh.cache.Get(etagKey, &etag); err != nil {
-		log2.WithError(err).Warnf("failed to get %s from cache", etagKey)
+	var etag string
+	if useEtag {
+		etag = gh.cachedEtag(etagKey)
 	}
 
 	resp, err := gh.makeRepoRequest(ctx, name, etag)
 	if err != nil {
 		return repo, err
 	}
+	defer resp.Body.Close()
 	bts, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return repo, err
 	}
-	defer resp.Body.Close()
 
 	switch resp.StatusCode {
 	case http.StatusNotModified:
 		log.Info("not modified")
 		effectiveEtags.Inc()
-		err := gh.cache.Get(name, &repo)
-		if err != nil {
-			log.WithError(err).Warnf("failed to get %s from cache", name)
-			if err := gh.cache.Delete(etagKey); err != nil {
-				log.WithError(err).Warnf("failed to delete %s from cache", etagKey)
-			}
-			return gh.RepoDetails(ctx, name)
+		if err := gh.cache.Get(name, &repo); err != nil {
+			log.WithError(err).Warnf("failed to get %s from cache, refetching", name)
+			gh.deleteEtag(etagKey)
+			return gh.repoDetails(ctx, name, false)
 		}
-		return repo, err
+		return repo, nil
 
-	case http.StatusForbidden:
-		rateLimits.Inc()
-		log.Warn("rate limit hit")
-		return repo, ErrRateLimit
+	case http.StatusForbidden, http.StatusTooManyRequests:
+		err := forbiddenError(resp, bts)
+		log.WithError(err).Warn("request forbidden")
+		return repo, err
 
 	case http.StatusOK:
 		if err := json.Unmarshal(bts, &repo); err != nil {
 			return repo, err
 		}
-		if err := gh.cache.Put(name, repo); err != nil {
+		if err := gh.cache.Put(name, repo, gh.repoTTL); err != nil {
 			log.WithError(err).Warnf("failed to cache %s", name)
 		}
 
 		etag = resp.Header.Get("etag")
 		if etag != "" {
-			if err := gh.cache.Put(etagKey, etag); err != nil {
+			if err := gh.cache.Put(etagKey, etag, gh.repoTTL); err != nil {
 				log.WithError(err).Warnf("failed to cache %s", etagKey)
 			}
 		}
@@ -80,7 +86,7 @@ func (gh *GitHub) RepoDetails(ctx context.Context, name string) (Repository, err
 }
 
 func (gh *GitHub) makeRepoRequest(ctx context.Context, name, etag string) (*http.Response, error) {
-	url := fmt.Sprintf("https://api.github.com/repos/%s", name)
+	url := fmt.Sprintf("%s/repos/%s", gh.apiURL, name)
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 
 	if err != nil {
@@ -21,45 +21,152 @@ var (
 
 type Stargazer struct {
 	StarredAt time.Time `json:"starred_at"`
+	User      User      `json:"user"`
 }
 
-func (gh *GitHub) Stargazers(ctx context.Context, repo Repository) (stars []Stargazer, err error) {
-	if gh.totalPages(repo) > 400 {
-		return stars, ErrTooManyStars
+// User is the account that starred a repository.
+type User struct {
+	Login string `json:"login"`
+	ID    int64  `json:"id"`
+}
+
+// PageError is a stargazers page that could not be fetched.
+type PageError struct {
+	Page int
+	Err  error
+}
+
+// StargazersResult holds the stargazers of a repository, sorted by the time
+// they starred it, along with the pages they were fetched from and the pages
+// that failed.
+type StargazersResult struct {
+	Stargazers   []Stargazer
+	FetchedPages []int
+	FailedPages  []PageError
+}
+
+// Complete reports whether every page was fetched.
+func (r StargazersResult) Complete() bool {
+	return len(r.FailedPages) == 0
+}
+
+// Pages is the number of pages that were requested.
+func (r StargazersResult) Pages() int {
+	return len(r.FetchedPages) + len(r.FailedPages)
+}
+
+// Stargazers fetches every stargazers page of the repository, with the
+// configured fetcher. Pages that fail are listed in the result instead of
+// failing the whole listing, an error is only returned when nothing could be
+// fetched at all. Concurrent callers for the same repository share the
+// listing, so the result must not be modified.
+func (gh *GitHub) Stargazers(ctx context.Context, repo Repository) (StargazersResult, error) {
+	return gh.starsFlights.Do(ctx, repo.FullName, func(ctx context.Context) (StargazersResult, error) {
+		lock := "stargazers/" + repo.FullName
+		release, fresh, err := gh.lockFetch(ctx, lock)
+		if err != nil {
+			return StargazersResult{}, err
+		}
+		defer release()
+		result, err := gh.stargazers(ctx, repo, fresh)
+		if err == nil && result.Complete() {
+			gh.markFetched(lock)
+		}
+		return result, err
+	})
+}
+
+// stargazers lists the stargazers of the repository. Pages cached are used
+// as they are when fresh, as another instance just fetched them all.
+func (gh *GitHub) stargazers(ctx context.Context, repo Repository, fresh bool) (StargazersResult, error) {
+	if gh.fetcher == FetcherGraphQL {
+		return gh.graphqlStargazers(ctx, repo)
+	}
+
+	var result StargazersResult
+	if gh.lastPage(repo) > gh.maxPages() {
+		return result, ErrTooManyStars
+	}
+
+	// full pages of the last sync can't change while stars are only added,
+	// so only the pages after them are fetched.
+	first := 1
+	state, ok := gh.syncState(repo)
+	if ok && repo.StargazersCount >= state.Count && state.FullPages < gh.lastPage(repo) {
+		log2.WithField("repo", repo.FullName).WithField("pages", state.FullPages).Debug("resuming sync")
+		first = state.FullPages + 1
+		result.Stargazers = append(result.Stargazers, state.Stargazers...)
+		for page := 1; page < first; page++ {
+			result.FetchedPages = append(result.FetchedPages, page)
+		}
 	}
 
+	gh.progress.start(repo.FullName, first-1, gh.lastPage(repo))
+	defer gh.progress.finish(repo.FullName)
+
 	var (
-		wg   errgroup.Group
-		lock sync.Mutex
+		wg    errgroup.Group
+		lock  sync.Mutex
+		pages = map[int][]Stargazer{}
 	)
 
 	wg.SetLimit(4)
-	for page := 1; page <= gh.lastPage(repo); page++ {
+	for page := first; page <= gh.lastPage(repo); page++ {
 		page := page
 		wg.Go(func() error {
-			result, err := gh.getStarGazersPage(ctx, repo, page)
-			if errors.Is(err, errNoMorePages) {
-				return nil
-			}
-			if err != nil {
-				return err
-			}
+			stars, err := gh.starGazersPage(ctx, repo, page, fresh)
+			gh.progress.advance(repo.FullName)
 			lock.Lock()
 			defer lock.Unlock()
-			stars = append(stars, result...)
+			if err != nil && !errors.Is(err, errNoMorePages) {
+				log2.WithField("repo", repo.FullName).WithField("page", page).WithError(err).Warn("failed to get page")
+				result.FailedPages = append(result.FailedPages, PageError{Page: page, Err: err})
+				return nil
+			}
+			result.FetchedPages = append(result.FetchedPages, page)
+			pages[page] = stars
 			return nil
 		})
 	}
+	_ = wg.Wait()
 
-	err = wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
 
-	sort.Slice(stars, func(i, j int) bool {
-		return stars[i].StarredAt.Before(stars[j].StarredAt)
+	sort.Ints(result.FetchedPages)
+	sort.Slice(result.FailedPages, func(i, j int) bool {
+		return result.FailedPages[i].Page < result.FailedPages[j].Page
 	})
-	return
+	if len(result.FetchedPages) == 0 && len(result.FailedPages) > 0 {
+		return result, result.FailedPages[0].Err
+	}
+
+	if result.Complete() {
+		gh.saveSyncState(repo, first, state, pages)
+	}
+	for page := first; page <= gh.lastPage(repo); page++ {
+		result.Stargazers = append(result.Stargazers, pages[page]...)
+	}
+	sort.SliceStable(result.Stargazers, func(i, j int) bool {
+		return result.Stargazers[i].StarredAt.Before(result.Stargazers[j].StarredAt)
+	})
+	return result, nil
+}
+
+// starGazersPage gets the page from the cache when fresh, from GitHub when
+// not or when it isn't cached.
+func (gh *GitHub) starGazersPage(ctx context.Context, repo Repository, page int, fresh bool) ([]Stargazer, error) {
+	if fresh {
+		var stars []Stargazer
+		if err := gh.cache.Get(fmt.Sprintf("%s_%d", repo.FullName, page), &stars); err == nil {
+			return stars, nil
+		}
+	}
+	return gh.getStarGazersPage(ctx, repo, page, true)
 }
 
-func (gh *GitHub) getStarGazersPage(ctx context.Context, repo Repository, page int) ([]Stargazer, error) {
+func (gh *GitHub) getStarGazersPage(ctx context.Context, repo Repository, page int, useEtag bool) ([]Stargazer, error) {
 	log := log2.WithField("repo", repo.FullName).WithField("page", page)
 	defer log.Trace("get page").Stop(nil)
 	var stars []Stargazer
@@ -67,39 +174,36 @@ func (gh *GitHub) getStarGazersPage(ctx context.Context, repo Repository, page i
 	etagKey := fmt.Sprintf("%s_%d", repo.FullName, page) + "_etag"
 
 	var etag string
-	if err := gh.cache.Get(etagKey, &etag); err != nil {
-		log.WithError(err).Warnf("failed to get %s from cache", etagKey)
+	if useEtag {
+		etag = gh.cachedEtag(etagKey)
 	}
 
 	resp, err := gh.makeStarPageRequest(ctx, repo, page, etag)
 	if err != nil {
-		return stars, nil
+		return stars, err
 	}
+	defer resp.Body.Close()
 
 	bts, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return stars, nil
+		return stars, err
 	}
-	defer resp.Body.Close()
 
 	switch resp.StatusCode {
 	case http.StatusNotModified:
 		effectiveEtags.Inc()
 		log.Info("not modified")
-		err := gh.cache.Get(key, &stars)
-		if err != nil {
-			log.WithError(err).Warnf("failed to get %s from cache", key)
-			if err := gh.cache.Delete(etagKey); err != nil {
-				log.WithError(err).Warnf("failed to delete %s from cache", etagKey)
-			}
-			return gh.getStarGazersPage(ctx, repo, page)
+		if err := gh.cache.Get(key, &stars); err != nil {
+			log.WithError(err).Warnf("failed to get %s from cache, refetching", key)
+			gh.deleteEtag(etagKey)
+			return gh.getStarGazersPage(ctx, repo, page, false)
 		}
-		return stars, err
+		return stars, nil
 
-	case http.StatusForbidden:
-		rateLimits.Inc()
-		log.Warn("rate limit hit")
-		return stars, ErrRateLimit
+	case http.StatusForbidden, http.StatusTooManyRequests:
+		err := forbiddenError(resp, bts)
+		log.WithError(err).Warn("request forbidden")
+		return stars, err
 
 	case http.StatusOK:
 		if err := json.Unmarshal(bts, &stars); err != nil {
@@ -108,13 +212,13 @@ func (gh *GitHub) getStarGazersPage(ctx context.Context, repo Repository, page i
 		if len(stars) == 0 {
 			return stars, errNoMorePages
 		}
-		if err := gh.cache.Put(key, stars); err != nil {
+		if err := gh.cache.Put(key, stars, gh.stargazersTTL); err != nil {
 			log.WithError(err).Warnf("failed to cache %s", key)
 		}
 
 		etag = resp.Header.Get("etag")
 		if etag != "" {
-			if err := gh.cache.Put(etagKey, etag); err != nil {
+			if err := gh.cache.Put(etagKey, etag, gh.stargazersTTL); err != nil {
 				log.WithError(err).Warnf("failed to cache %s", etagKey)
 			}
 		}
@@ -132,8 +236,14 @@ func (gh *GitHub) lastPage(repo Repository) int {
 	return gh.totalPages(repo) + 1
 }
 
+// maxPages is the last page GitHub lists stargazers for.
+func (gh *GitHub) maxPages() int {
+	return maxListedStars / gh.pageSize
+}
+
 func (gh *GitHub) makeStarPageRequest(ctx context.Context, repo Repository, page int, etag string) (*http.Response, error) {
-	url := fmt.Sprintf("https://api.github.com/repos/%s/stargazers?page=%d&per_page=%d",
+	url := fmt.Sprintf("%s/repos/%s/stargazers?page=%d&per_page=%d",
+		gh.apiURL,
 		repo.FullName,
 		page,
 		gh.pageSize)
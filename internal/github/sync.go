@@ -0,0 +1,60 @@
+package github
+
+import (
+	"errors"
+	log2 "github.com/apex/log"
+	"strarcharts/internal/cache"
+)
+
+// syncState is what the last complete listing of a repository's stargazers
+// left behind: the leading pages that were full, which GitHub won't change
+// while stars are only added, and their stargazers.
+type syncState struct {
+	Count      int
+	FullPages  int
+	Stargazers []Stargazer
+}
+
+func syncKey(repo Repository) string {
+	return repo.FullName + "_sync"
+}
+
+// syncState returns the state of the last sync, if any and still usable.
+func (gh *GitHub) syncState(repo Repository) (syncState, bool) {
+	var state syncState
+	if err := gh.cache.Get(syncKey(repo), &state); err != nil {
+		if !errors.Is(err, cache.ErrCacheMiss) {
+			log2.WithError(err).WithField("repo", repo.FullName).Warn("failed to get sync state")
+		}
+		return state, false
+	}
+	if state.Count > repo.StargazersCount {
+		// stars were removed and the pages shifted, start over.
+		log2.WithField("repo", repo.FullName).Info("stars removed, syncing again")
+		return state, false
+	}
+	return state, true
+}
+
+// saveSyncState extends the previous state, whose full pages end right before
+// first, with the full pages that follow them.
+func (gh *GitHub) saveSyncState(repo Repository, first int, previous syncState, pages map[int][]Stargazer) {
+	state := syncState{Count: repo.StargazersCount}
+	if first > 1 {
+		state.FullPages = previous.FullPages
+		state.Stargazers = previous.Stargazers
+	}
+	for page := first; len(pages[page]) == gh.pageSize; page++ {
+		state.FullPages = page
+		state.Stargazers = append(state.Stargazers, pages[page]...)
+	}
+	if first > 1 && state.FullPages == previous.FullPages && state.Count == previous.Count {
+		// nothing new, let the state expire so a full sync eventually
+		// catches stars that were removed and replaced.
+		return
+	}
+
+	if err := gh.cache.Put(syncKey(repo), state, gh.stargazersTTL); err != nil {
+		log2.WithError(err).WithField("repo", repo.FullName).Warn("failed to save sync state")
+	}
+}
@@ -0,0 +1,125 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strarcharts/config"
+	"strarcharts/internal/cache"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeStargazers serves the stargazers of o/r, count of them with one
+// starred every minute, and records the pages requested. Failed pages answer
+// with a server error.
+type fakeStargazers struct {
+	lock   sync.Mutex
+	count  int
+	failed map[int]bool
+	pages  []int
+}
+
+func (f *fakeStargazers) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.pages = append(f.pages, page)
+	if f.failed[page] {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	stars := []Stargazer{}
+	for k := (page-1)*perPage + 1; k <= page*perPage && k <= f.count; k++ {
+		stars = append(stars, Stargazer{StarredAt: starredAt(k)})
+	}
+	_ = json.NewEncoder(w).Encode(stars)
+}
+
+// requested returns the pages requested since the last call, sorted.
+func (f *fakeStargazers) requested() []int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	pages := f.pages
+	f.pages = nil
+	sort.Ints(pages)
+	return pages
+}
+
+func starredAt(k int) time.Time {
+	return time.Date(2020, 1, 1, 0, k, 0, 0, time.UTC)
+}
+
+func TestStargazersSyncResume(t *testing.T) {
+	for name, tt := range map[string]struct {
+		counts []int
+		want   [][]int
+	}{
+		"first sync": {
+			counts: []int{250},
+			want:   [][]int{{1, 2, 3}},
+		},
+		"stars added": {
+			counts: []int{250, 320},
+			want:   [][]int{{1, 2, 3}, {3, 4}},
+		},
+		"no new stars": {
+			counts: []int{250, 250},
+			want:   [][]int{{1, 2, 3}, {3}},
+		},
+		"last page filled": {
+			counts: []int{250, 300, 310},
+			want:   [][]int{{1, 2, 3}, {3, 4}, {4}},
+		},
+		"stars removed": {
+			counts: []int{250, 240},
+			want:   [][]int{{1, 2, 3}, {1, 2, 3}},
+		},
+		"added after removed": {
+			counts: []int{250, 240, 260},
+			want:   [][]int{{1, 2, 3}, {1, 2, 3}, {3}},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			fake := &fakeStargazers{}
+			srv := httptest.NewServer(fake)
+			defer srv.Close()
+			gh := New(config.Config{
+				GithubApiUrl:            srv.URL,
+				GithubStargazersFetcher: FetcherREST,
+				GithubPageSize:          100,
+				GithubTimeout:           time.Second,
+				CacheStargazersTTL:      time.Hour,
+			}, cache.NewMemory(0, 0, 0))
+
+			for i, count := range tt.counts {
+				fake.lock.Lock()
+				fake.count = count
+				fake.lock.Unlock()
+				repo := Repository{FullName: "o/r", StargazersCount: count}
+				result, err := gh.Stargazers(context.Background(), repo)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if pages := fake.requested(); !reflect.DeepEqual(pages, tt.want[i]) {
+					t.Errorf("sync %d: expected pages %v, got %v", i, tt.want[i], pages)
+				}
+				if len(result.Stargazers) != count {
+					t.Fatalf("sync %d: expected %d stargazers, got %d", i, count, len(result.Stargazers))
+				}
+				for k, star := range result.Stargazers {
+					if !star.StarredAt.Equal(starredAt(k + 1)) {
+						t.Fatalf("sync %d: stargazer %d starred at %s", i, k, star.StarredAt)
+					}
+				}
+			}
+		})
+	}
+}
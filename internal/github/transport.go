@@ -0,0 +1,184 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/apex/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrForbidden = errors.New("access forbidden by github")
+
+var retries = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "starcharts",
+	Subsystem: "github",
+	Name:      "retries_total",
+}, []string{"reason"})
+
+func init() {
+	prometheus.MustRegister(retries)
+}
+
+const (
+	minBackoff = 250 * time.Millisecond
+	maxBackoff = 8 * time.Second
+)
+
+// transport retries GitHub requests with exponential backoff and jitter on
+// network errors and 5xx responses, and waits for the Retry-After of
+// secondary rate limits if it isn't longer than maxRetryAfter. Every attempt
+// has its own timeout.
+type transport struct {
+	base          http.RoundTripper
+	timeout       time.Duration
+	maxRetries    int
+	maxRetryAfter time.Duration
+}
+
+func newTransport(timeout time.Duration, maxRetries int, maxRetryAfter time.Duration) *transport {
+	return &transport{
+		base:          http.DefaultTransport,
+		timeout:       timeout,
+		maxRetries:    maxRetries,
+		maxRetryAfter: maxRetryAfter,
+	}
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	current := req
+	for attempt := 0; ; attempt++ {
+		resp, err := t.attempt(current)
+
+		if req.Context().Err() != nil {
+			return resp, err
+		}
+		wait, reason := t.retryAfter(resp, err, attempt)
+		if reason == "" || attempt >= t.maxRetries {
+			return resp, err
+		}
+		retry, ok := rewind(req)
+		if !ok {
+			return resp, err
+		}
+		current = retry
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		log.WithField("url", req.URL.String()).
+			WithField("reason", reason).
+			WithField("attempt", attempt+1).
+			Warnf("retrying in %s", wait)
+		retries.WithLabelValues(reason).Inc()
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (t *transport) attempt(req *http.Request) (*http.Response, error) {
+	if t.timeout <= 0 {
+		return t.base.RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// retryAfter tells how long to wait before retrying and why, an empty reason
+// meaning the request shouldn't be retried.
+func (t *transport) retryAfter(resp *http.Response, err error, attempt int) (time.Duration, string) {
+	if err != nil {
+		return backoff(attempt), "network"
+	}
+
+	switch {
+	case resp.StatusCode >= 500:
+		return backoff(attempt), "server_error"
+	case resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests:
+		seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+		if err != nil || time.Duration(seconds)*time.Second > t.maxRetryAfter {
+			return 0, ""
+		}
+		return time.Duration(seconds) * time.Second, "secondary_rate_limit"
+	default:
+		return 0, ""
+	}
+}
+
+// backoff doubles the wait on every attempt, picking a random duration in the
+// upper half so concurrent retries spread out.
+func backoff(attempt int) time.Duration {
+	wait := min(maxBackoff, minBackoff<<attempt)
+	return wait/2 + rand.N(wait/2)
+}
+
+// rewind returns a copy of req with a fresh body so it can be sent again,
+// reporting whether that was possible. req itself is left as it is, a
+// RoundTripper mustn't modify the requests it is given.
+func rewind(req *http.Request) (*http.Request, bool) {
+	retry := req.Clone(req.Context())
+	if req.Body == nil || req.Body == http.NoBody {
+		return retry, true
+	}
+	if req.GetBody == nil {
+		return nil, false
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, false
+	}
+	retry.Body = body
+	return retry, true
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
+
+// isRateLimited tells a rate limited response apart from a 403 caused by the
+// token lacking permissions.
+func isRateLimited(resp *http.Response, body []byte) bool {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusForbidden:
+		return resp.Header.Get("X-RateLimit-Remaining") == "0" ||
+			resp.Header.Get("Retry-After") != "" ||
+			bytes.Contains(bytes.ToLower(body), []byte("rate limit"))
+	default:
+		return false
+	}
+}
+
+// forbiddenError is the error for a 403 or 429 response.
+func forbiddenError(resp *http.Response, body []byte) error {
+	if isRateLimited(resp, body) {
+		rateLimits.Inc()
+		return ErrRateLimit
+	}
+	return fmt.Errorf("%w: %s", ErrForbidden, strings.TrimSpace(string(body)))
+}
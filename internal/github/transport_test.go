@@ -0,0 +1,50 @@
+package github
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTransportRetryKeepsRequest(t *testing.T) {
+	var (
+		lock   sync.Mutex
+		bodies []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		lock.Lock()
+		defer lock.Unlock()
+		bodies = append(bodies, string(body))
+		if len(bodies) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader("query"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := req.Body
+	resp, err := newTransport(time.Second, 1, 0).RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the retry to succeed, got %d", resp.StatusCode)
+	}
+	if len(bodies) != 2 || bodies[0] != "query" || bodies[1] != "query" {
+		t.Fatalf("expected the body sent twice, got %q", bodies)
+	}
+	// the request belongs to the caller, retries send copies of it.
+	if req.Body != body {
+		t.Fatal("expected the request body to be left as it was")
+	}
+}
@@ -0,0 +1,174 @@
+// Package jobs runs work in the background, at most once at a time per key,
+// and keeps track of how it went.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"github.com/apex/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"sync"
+	"time"
+)
+
+// ErrQueueFull is returned by Enqueue when no more jobs can be queued.
+var ErrQueueFull = errors.New("jobs: queue is full")
+
+// State is where a job is at.
+type State string
+
+const (
+	Queued  State = "queued"
+	Running State = "running"
+	Done    State = "done"
+	Failed  State = "failed"
+)
+
+// Status is the state of the last job enqueued with a key.
+type Status struct {
+	State     State     `json:"state"`
+	Error     string    `json:"error,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// finished reports whether the job is over, successfully or not.
+func (s Status) finished() bool {
+	return s.State == Done || s.State == Failed
+}
+
+var queuedJobs = prometheus.NewGauge(prometheus.GaugeOpts{
+	Namespace: "starcharts",
+	Subsystem: "jobs",
+	Name:      "queued",
+	Help:      "jobs waiting for a worker",
+})
+
+var finishedJobs = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "starcharts",
+	Subsystem: "jobs",
+	Name:      "finished_total",
+	Help:      "jobs that finished, by state",
+}, []string{"state"})
+
+func init() {
+	prometheus.MustRegister(queuedJobs, finishedJobs)
+}
+
+type job struct {
+	key string
+	run func(ctx context.Context) error
+}
+
+// Queue runs jobs with a fixed number of workers.
+type Queue struct {
+	jobs    chan job
+	timeout time.Duration
+	keep    time.Duration
+
+	lock     sync.Mutex
+	statuses map[string]Status
+	// again holds the jobs enqueued while one with the same key was
+	// running, they are run once it is over.
+	again map[string]job
+}
+
+// New starts a queue holding up to size jobs, run by workers workers. Each
+// job gets at most timeout to run, and its status is kept for keep after it
+// finished.
+func New(workers, size int, timeout, keep time.Duration) *Queue {
+	q := &Queue{
+		jobs:     make(chan job, size),
+		timeout:  timeout,
+		keep:     keep,
+		statuses: map[string]Status{},
+		again:    map[string]job{},
+	}
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+	return q
+}
+
+// Enqueue queues run under key, unless a job with the same key is already
+// queued. A job enqueued while one with the same key is running runs once it
+// is over, so it sees what changed meanwhile.
+func (q *Queue) Enqueue(key string, run func(ctx context.Context) error) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.prune()
+	switch q.statuses[key].State {
+	case Queued:
+		return nil
+	case Running:
+		q.again[key] = job{key: key, run: run}
+		return nil
+	}
+
+	select {
+	case q.jobs <- job{key: key, run: run}:
+		queuedJobs.Inc()
+		q.statuses[key] = Status{State: Queued, UpdatedAt: time.Now()}
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Status returns the status of the last job enqueued with key, if it is
+// still known.
+func (q *Queue) Status(key string) (Status, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	status, ok := q.statuses[key]
+	return status, ok
+}
+
+func (q *Queue) work() {
+	for job := range q.jobs {
+		queuedJobs.Dec()
+		q.setStatus(job.key, Status{State: Running})
+
+		log := log.WithField("job", job.key)
+		ctx, cancel := context.WithTimeout(context.Background(), q.timeout)
+		err := job.run(ctx)
+		cancel()
+
+		if err != nil {
+			log.WithError(err).Error("job failed")
+			q.setStatus(job.key, Status{State: Failed, Error: err.Error()})
+			continue
+		}
+		log.Info("job done")
+		q.setStatus(job.key, Status{State: Done})
+	}
+}
+
+func (q *Queue) setStatus(key string, status Status) {
+	if status.finished() {
+		finishedJobs.WithLabelValues(string(status.State)).Inc()
+	}
+	status.UpdatedAt = time.Now()
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if next, ok := q.again[key]; ok && status.finished() {
+		delete(q.again, key)
+		select {
+		case q.jobs <- next:
+			queuedJobs.Inc()
+			status = Status{State: Queued, UpdatedAt: status.UpdatedAt}
+		default:
+			log.WithField("job", key).Warn("queue is full, dropping job enqueued while running")
+		}
+	}
+	q.statuses[key] = status
+}
+
+// prune forgets the jobs finished for longer than keep.
+func (q *Queue) prune() {
+	for key, status := range q.statuses {
+		if status.finished() && time.Since(status.UpdatedAt) > q.keep {
+			delete(q.statuses, key)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package jobs
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func waitFinished(t *testing.T, q *Queue, key string) Status {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if status, ok := q.Status(key); ok && status.finished() {
+			return status
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", key)
+	return Status{}
+}
+
+func TestEnqueueWhileRunning(t *testing.T) {
+	q := New(1, 10, time.Second, time.Minute)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var runs atomic.Int32
+	run := func(ctx context.Context) error {
+		if runs.Add(1) == 1 {
+			close(started)
+			<-release
+		}
+		return nil
+	}
+
+	if err := q.Enqueue("key", run); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	// both are run once the running job is over, as one job.
+	for range 2 {
+		if err := q.Enqueue("key", run); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(release)
+
+	if status := waitFinished(t, q, "key"); status.State != Done {
+		t.Fatalf("expected done, got %s", status.State)
+	}
+	if n := runs.Load(); n != 2 {
+		t.Fatalf("expected 2 runs, got %d", n)
+	}
+}
+
+func TestEnqueueWhileQueued(t *testing.T) {
+	q := New(1, 10, time.Second, time.Minute)
+	release := make(chan struct{})
+	if err := q.Enqueue("busy", func(ctx context.Context) error {
+		<-release
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var runs atomic.Int32
+	for range 3 {
+		if err := q.Enqueue("key", func(ctx context.Context) error {
+			runs.Add(1)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(release)
+
+	waitFinished(t, q, "key")
+	if n := runs.Load(); n != 1 {
+		t.Fatalf("expected 1 run, got %d", n)
+	}
+}
@@ -0,0 +1,14 @@
+package roundrobin
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var tokenStates = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "starcharts",
+	Subsystem: "github",
+	Name:      "token_state",
+	Help:      "Whether each token is valid, parked until its quota resets or invalid",
+}, []string{"token", "state"})
+
+func init() {
+	prometheus.MustRegister(tokenStates)
+}
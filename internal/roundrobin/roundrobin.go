@@ -1,24 +1,51 @@
 package roundrobin
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"github.com/apex/log"
 	"sync"
-	"sync/atomic"
+	"time"
 )
 
 type Token struct {
-	token string
-	valid bool
-	lock  sync.RWMutex
+	token       string
+	valid       bool
+	rate        Rate
+	parkedUntil time.Time
+	lock        sync.RWMutex
 }
 
+// Rate is the API quota of a token, as last reported by GitHub.
+type Rate struct {
+	Remaining int
+	Limit     int
+	Reset     time.Time
+	// Unlimited is set when the API doesn't rate limit at all, like GitHub
+	// Enterprise Server with rate limiting disabled.
+	Unlimited bool
+}
+
+// ErrNoAcceptedToken is returned by PickWhere when there are usable tokens,
+// but none of them was accepted.
+var ErrNoAcceptedToken = errors.New("no usable token accepted")
+
 type RoundRobiner interface {
 	Pick() (*Token, error)
+	// PickWhere is the same as Pick, only considering the tokens accept
+	// returns true for.
+	PickWhere(accept func(token *Token) bool) (*Token, error)
 }
 
+// Validator checks whether a token revoked earlier works again.
+type Validator func(token *Token) error
+
 // The New() function is a constructor used to create a new RoundRobin instance.
-func New(tokens []string) RoundRobiner {
+// Invalidated tokens are checked with validate every interval, and put back
+// in rotation once it succeeds. A nil validate leaves them out for good.
+func New(tokens []string, validate Validator, interval time.Duration) RoundRobiner {
 	log.Debugf("create round robin with %d tokens", len(tokens))
 	if len(tokens) == 0 {
 		return &noTokenRoundRobin{}
@@ -27,7 +54,11 @@ func New(tokens []string) RoundRobiner {
 	for _, item := range tokens {
 		result = append(result, NewToken(item))
 	}
-	return &realRoundRobin{tokens: result}
+	rr := &quotaRoundRobin{tokens: result}
+	if validate != nil && interval > 0 {
+		go rr.revalidateEvery(validate, interval)
+	}
+	return rr
 }
 
 type noTokenRoundRobin struct {
@@ -37,6 +68,10 @@ func (rr *noTokenRoundRobin) Pick() (*Token, error) {
 	return nil, nil
 }
 
+func (rr *noTokenRoundRobin) PickWhere(accept func(token *Token) bool) (*Token, error) {
+	return nil, nil
+}
+
 func NewToken(token string) *Token {
 	return &Token{
 		token: token,
@@ -44,45 +79,197 @@ func NewToken(token string) *Token {
 	}
 }
 
-type realRoundRobin struct {
+// quotaRoundRobin picks the usable token with the most remaining quota,
+// rotating between tokens with the same quota.
+type quotaRoundRobin struct {
 	tokens []*Token
-	next   int64
+	lock   sync.Mutex
+	next   int
 }
 
-func (rr *realRoundRobin) Pick() (*Token, error) {
-	return rr.doPick(0)
+func (rr *quotaRoundRobin) Pick() (*Token, error) {
+	return rr.PickWhere(nil)
 }
 
-func (rr *realRoundRobin) doPick(try int) (*Token, error) {
-	if try > len(rr.tokens) {
+func (rr *quotaRoundRobin) PickWhere(accept func(token *Token) bool) (*Token, error) {
+	rr.lock.Lock()
+	defer rr.lock.Unlock()
+	defer rr.report()
+
+	var pick *Token
+	best := -1
+	usable := false
+	for i := range rr.tokens {
+		token := rr.tokens[(rr.next+i)%len(rr.tokens)]
+		if !token.OK() {
+			continue
+		}
+		usable = true
+		if accept != nil && !accept(token) {
+			continue
+		}
+		if remaining := token.remaining(); remaining > best {
+			pick, best = token, remaining
+		}
+	}
+	rr.next = (rr.next + 1) % len(rr.tokens)
+
+	if pick == nil && usable {
+		return nil, ErrNoAcceptedToken
+	}
+	if pick == nil {
 		return nil, fmt.Errorf("no valid token left")
 	}
-	idx := atomic.LoadInt64(&rr.next)
-	atomic.StoreInt64(&rr.next, (idx+1)%int64(len(rr.tokens)))
-	if pick := rr.tokens[idx]; pick.OK() {
-		log.Debugf("picked %s", pick.Key())
-		return pick, nil
+	log.Debugf("picked %s", pick)
+	return pick, nil
+}
+
+func (rr *quotaRoundRobin) revalidateEvery(validate Validator, interval time.Duration) {
+	for range time.Tick(interval) {
+		for _, token := range rr.tokens {
+			if token.Valid() {
+				continue
+			}
+			if err := validate(token); err != nil {
+				log.WithError(err).Debugf("token %s is still invalid", token)
+				continue
+			}
+			token.Revalidate()
+		}
+		rr.lock.Lock()
+		rr.report()
+		rr.lock.Unlock()
 	}
-	return rr.doPick(try + 1)
 }
 
+// report exports the state of every token. Must be called with the lock held.
+func (rr *quotaRoundRobin) report() {
+	for _, token := range rr.tokens {
+		state := token.State()
+		for _, s := range []string{StateValid, StateParked, StateInvalid} {
+			value := 0.0
+			if s == state {
+				value = 1
+			}
+			tokenStates.WithLabelValues(token.ID(), s).Set(value)
+		}
+	}
+}
+
+const (
+	StateValid   = "valid"
+	StateParked  = "parked"
+	StateInvalid = "invalid"
+)
+
 func (t *Token) OK() bool {
+	return t.State() == StateValid
+}
+
+// Valid reports whether the token was not revoked, parked tokens are valid.
+func (t *Token) Valid() bool {
 	t.lock.RLock()
 	defer t.lock.RUnlock()
 	return t.valid
 }
 
-func (t *Token) Invalidate() {
-	log.Warnf("invalidated token '...%s'", t)
+// State tells whether the token can be used, is parked until its quota
+// resets or was revoked.
+func (t *Token) State() string {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	now := time.Now()
+	switch {
+	case !t.valid:
+		return StateInvalid
+	case now.Before(t.parkedUntil):
+		return StateParked
+	case t.rate.Limit > 0 && t.rate.Remaining == 0 && now.Before(t.rate.Reset):
+		return StateParked
+	default:
+		return StateValid
+	}
+}
+
+// Invalidate takes a revoked token out of rotation, reporting whether it was
+// valid until then.
+func (t *Token) Invalidate() bool {
 	t.lock.Lock()
 	defer t.lock.Unlock()
+	if !t.valid {
+		return false
+	}
+	log.Warnf("invalidated token '%s'", t)
 	t.valid = false
+	return true
 }
 
+// Revalidate puts a revoked token back in rotation.
+func (t *Token) Revalidate() {
+	log.Infof("revalidated token '%s'", t)
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.valid = true
+}
+
+// Park takes the token out of rotation until the given time, e.g. when its
+// quota resets.
+func (t *Token) Park(until time.Time) {
+	log.Infof("parked token '%s' until %s", t, until.Format(time.RFC3339))
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.parkedUntil = until
+}
+
+// SetRate records the quota GitHub reported for the token.
+func (t *Token) SetRate(rate Rate) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.rate = rate
+}
+
+// Rate returns the last known quota of the token. Once the reset time has
+// passed the quota is known to be full again. ok is false if GitHub never
+// reported it.
+func (t *Token) Rate() (rate Rate, ok bool) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	if t.rate.Unlimited {
+		return t.rate, true
+	}
+	if t.rate.Limit == 0 {
+		return t.rate, false
+	}
+	if time.Now().After(t.rate.Reset) {
+		return Rate{Remaining: t.rate.Limit, Limit: t.rate.Limit}, true
+	}
+	return t.rate, true
+}
+
+// remaining is the known remaining quota, tokens never used yet come first.
+func (t *Token) remaining() int {
+	rate, ok := t.Rate()
+	if !ok || rate.Unlimited {
+		return int(^uint(0) >> 1)
+	}
+	return rate.Remaining
+}
+
+// Key is the secret token, to be used only to authenticate requests.
 func (t *Token) Key() string {
 	return t.token
 }
 
+// ID identifies the token without exposing it, e.g. in metrics.
+func (t *Token) ID() string {
+	sum := sha256.Sum256([]byte(t.token))
+	return hex.EncodeToString(sum[:4])
+}
+
+// String redacts the token, so it can be logged.
 func (t *Token) String() string {
-	return t.token
+	if len(t.token) <= 4 {
+		return "..."
+	}
+	return "..." + t.token[len(t.token)-4:]
 }
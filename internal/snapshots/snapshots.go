@@ -0,0 +1,95 @@
+// Package snapshots records the star count of repositories once a day, which
+// keeps their history right when stars are removed or can't be listed.
+package snapshots
+
+import (
+	"context"
+	"github.com/apex/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"strarcharts/internal/github"
+	"strarcharts/internal/store"
+	"strarcharts/internal/tracked"
+	"time"
+)
+
+var taken = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "starcharts",
+	Subsystem: "snapshots",
+	Name:      "taken_total",
+	Help:      "star count snapshots taken, by result",
+}, []string{"result"})
+
+func init() {
+	prometheus.MustRegister(taken)
+}
+
+// Scheduler snapshots the star count of every tracked repository that has
+// none for the current day yet. Other repositories get a count recorded
+// whenever their stars are fetched.
+type Scheduler struct {
+	gh       *github.GitHub
+	history  *store.Store
+	repos    *tracked.List
+	interval time.Duration
+}
+
+// New creates a scheduler looking for repositories to snapshot every
+// interval.
+func New(gh *github.GitHub, history *store.Store, repos *tracked.List, interval time.Duration) *Scheduler {
+	return &Scheduler{gh: gh, history: history, repos: repos, interval: interval}
+}
+
+// Run takes snapshots until ctx is done, a non-positive interval disables
+// them. Snapshots are background GitHub work, live requests go first.
+func (s *Scheduler) Run(ctx context.Context) {
+	if s.interval <= 0 {
+		log.Info("snapshots disabled")
+		return
+	}
+	ctx = github.Background(ctx)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		s.snapshot(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// snapshot records the current star count of the repositories missing
+// today's, one at a time.
+func (s *Scheduler) snapshot(ctx context.Context) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	for _, name := range s.repos.Repos() {
+		if ctx.Err() != nil {
+			return
+		}
+		log := log.WithField("repo", name)
+		last, ok, err := s.history.LastCount(name)
+		if err != nil {
+			log.WithError(err).Warn("failed to get snapshots")
+			continue
+		}
+		if ok && !last.Time.Before(today) {
+			continue
+		}
+
+		repo, err := s.gh.RepoDetails(ctx, name)
+		if err != nil {
+			log.WithError(err).Warn("failed to get repository")
+			taken.WithLabelValues("failed").Inc()
+			continue
+		}
+		if err := s.history.RecordCount(name, time.Now(), repo.StargazersCount); err != nil {
+			log.WithError(err).Warn("failed to record snapshot")
+			taken.WithLabelValues("failed").Inc()
+			continue
+		}
+		log.WithField("stars", repo.StargazersCount).Info("snapshot taken")
+		taken.WithLabelValues("success").Inc()
+	}
+}
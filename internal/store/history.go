@@ -0,0 +1,268 @@
+package store
+
+import (
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Star is a user starring a repository.
+type Star struct {
+	StarredAt time.Time `json:"starred_at"`
+	Login     string    `json:"login,omitempty"`
+	ID        int64     `json:"id,omitempty"`
+}
+
+// starRecord is a line of the stars file, either a star or the removal of
+// a star stored before.
+type starRecord struct {
+	Star
+	Removed bool `json:"removed,omitempty"`
+}
+
+// starKey identifies a star, regardless of the time location.
+type starKey struct {
+	at    int64
+	login string
+	id    int64
+}
+
+func (s Star) key() starKey {
+	return starKey{at: s.StarredAt.UnixNano(), login: s.Login, id: s.ID}
+}
+
+// Count is the number of stars of a repository at a given time.
+type Count struct {
+	Time  time.Time `json:"time"`
+	Count int       `json:"count"`
+}
+
+type history struct {
+	name string
+	dir  string
+	// users is guarded by the lock of the store.
+	users int
+
+	lock          sync.Mutex
+	starsLoaded   bool
+	stars         []Star
+	seen          map[starKey]int
+	starsPartial  bool
+	counts        []Count
+	countsPartial bool
+}
+
+// apply updates the history with a record read or appended.
+func (h *history) apply(record starRecord) {
+	key := record.key()
+	if !record.Removed {
+		h.stars = append(h.stars, record.Star)
+		h.seen[key]++
+		return
+	}
+	if h.seen[key] == 0 {
+		return
+	}
+	h.seen[key]--
+	for i, star := range h.stars {
+		if star.key() == key {
+			h.stars = append(h.stars[:i], h.stars[i+1:]...)
+			break
+		}
+	}
+}
+
+// loadStars reads the stars file the first time the stars are needed. Must
+// be called with the lock held.
+func (h *history) loadStars() error {
+	if h.starsLoaded {
+		return nil
+	}
+	partial, err := readRecords(filepath.Join(h.dir, starsFile), h.apply)
+	if err != nil {
+		h.stars, h.seen = nil, map[starKey]int{}
+		return err
+	}
+	sortStars(h.stars)
+	h.starsPartial, h.starsLoaded = partial, true
+	return nil
+}
+
+// write appends the records to the stars file and applies them.
+func (h *history) write(records []starRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	if err := appendRecords(filepath.Join(h.dir, starsFile), h.starsPartial, "star", records); err != nil {
+		return err
+	}
+	h.starsPartial = false
+	for _, record := range records {
+		h.apply(record)
+	}
+	sortStars(h.stars)
+	return nil
+}
+
+func sortStars(stars []Star) {
+	sort.SliceStable(stars, func(i, j int) bool {
+		return stars[i].StarredAt.Before(stars[j].StarredAt)
+	})
+}
+
+// Stars returns the stars stored for the repository, sorted by the time they
+// were added.
+func (s *Store) Stars(name string) ([]Star, error) {
+	h, release, err := s.history(name)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if err := h.loadStars(); err != nil {
+		return nil, err
+	}
+	return append([]Star(nil), h.stars...), nil
+}
+
+// AddStars stores the stars that are not stored yet, and returns how many
+// there were. Identical stars are told apart by how many times they appear,
+// as stars without a user may share their time.
+func (s *Store) AddStars(name string, stars []Star) (int, error) {
+	h, release, err := s.history(name)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if err := h.loadStars(); err != nil {
+		return 0, err
+	}
+
+	added, _ := h.diff(stars)
+	return len(added), h.write(added)
+}
+
+// SyncStars makes the stored stars match the complete listing of the
+// repository stars: the missing ones are added and the ones that are not
+// listed anymore are recorded as removed. It returns how many stars were
+// added and removed.
+func (s *Store) SyncStars(name string, stars []Star) (added, removed int, err error) {
+	h, release, err := s.history(name)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer release()
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if err := h.loadStars(); err != nil {
+		return 0, 0, err
+	}
+
+	additions, removals := h.diff(stars)
+	return len(additions), len(removals), h.write(append(additions, removals...))
+}
+
+// diff returns the records adding the stars that are not stored yet and the
+// ones removing the stored stars that are not in the list.
+func (h *history) diff(stars []Star) (added, removed []starRecord) {
+	occurrences := map[starKey]int{}
+	for _, star := range stars {
+		key := star.key()
+		occurrences[key]++
+		if occurrences[key] > h.seen[key] {
+			added = append(added, starRecord{Star: star})
+		}
+	}
+	for _, star := range h.stars {
+		key := star.key()
+		if occurrences[key] < h.seen[key] {
+			// counted up so only the extra occurrences are removed.
+			occurrences[key]++
+			removed = append(removed, starRecord{Star: star, Removed: true})
+		}
+	}
+	return added, removed
+}
+
+// RecordCount stores the number of stars the repository had at the given
+// time, unless it is the same as the last one recorded that day.
+func (s *Store) RecordCount(name string, at time.Time, count int) error {
+	h, release, err := s.history(name)
+	if err != nil {
+		return err
+	}
+	defer release()
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if n := len(h.counts); n > 0 {
+		last := h.counts[n-1]
+		if last.Count == count && day(last.Time).Equal(day(at)) {
+			return nil
+		}
+	}
+	record := Count{Time: at.UTC(), Count: count}
+	if err := appendRecords(filepath.Join(h.dir, countsFile), h.countsPartial, "count", []Count{record}); err != nil {
+		return err
+	}
+	h.countsPartial = false
+	h.counts = append(h.counts, record)
+	return nil
+}
+
+// DailyCounts returns the last count recorded each day for the repository,
+// sorted by time.
+func (s *Store) DailyCounts(name string) ([]Count, error) {
+	h, release, err := s.history(name)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	latest := map[time.Time]Count{}
+	for _, count := range h.counts {
+		d := day(count.Time)
+		if previous, ok := latest[d]; !ok || !count.Time.Before(previous.Time) {
+			latest[d] = count
+		}
+	}
+	counts := make([]Count, 0, len(latest))
+	for _, count := range latest {
+		counts = append(counts, count)
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		return counts[i].Time.Before(counts[j].Time)
+	})
+	return counts, nil
+}
+
+// LastCount returns the last count recorded for the repository, without
+// loading its stars, and whether there is one.
+func (s *Store) LastCount(name string) (Count, bool, error) {
+	h, release, err := s.history(name)
+	if err != nil {
+		return Count{}, false, err
+	}
+	defer release()
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	var last Count
+	for _, count := range h.counts {
+		if !count.Time.Before(last.Time) {
+			last = count
+		}
+	}
+	return last, len(h.counts) > 0, nil
+}
+
+// day truncates the time to the start of its UTC day.
+func day(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
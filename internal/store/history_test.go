@@ -0,0 +1,193 @@
+package store
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func star(minute int, login string) Star {
+	return Star{StarredAt: time.Date(2024, 1, 1, 0, minute, 0, 0, time.UTC), Login: login}
+}
+
+func added(stars ...Star) []starRecord {
+	records := make([]starRecord, 0, len(stars))
+	for _, star := range stars {
+		records = append(records, starRecord{Star: star})
+	}
+	return records
+}
+
+func removed(stars ...Star) []starRecord {
+	records := make([]starRecord, 0, len(stars))
+	for _, star := range stars {
+		records = append(records, starRecord{Star: star, Removed: true})
+	}
+	return records
+}
+
+func newHistory(records ...starRecord) *history {
+	h := &history{seen: map[starKey]int{}}
+	for _, record := range records {
+		h.apply(record)
+	}
+	sortStars(h.stars)
+	return h
+}
+
+func TestHistoryApply(t *testing.T) {
+	for name, tt := range map[string]struct {
+		records []starRecord
+		want    []Star
+	}{
+		"no records": {},
+		"added": {
+			records: added(star(1, "a"), star(2, "b")),
+			want:    []Star{star(1, "a"), star(2, "b")},
+		},
+		"removed": {
+			records: append(added(star(1, "a"), star(2, "b")), removed(star(1, "a"))...),
+			want:    []Star{star(2, "b")},
+		},
+		"removed then added again": {
+			records: append(append(added(star(1, "a")), removed(star(1, "a"))...), added(star(1, "a"))...),
+			want:    []Star{star(1, "a")},
+		},
+		"removal of a star never added": {
+			records: append(added(star(1, "a")), removed(star(2, "b"))...),
+			want:    []Star{star(1, "a")},
+		},
+		"one of identical stars removed": {
+			records: append(added(star(1, ""), star(1, ""), star(1, "")), removed(star(1, ""))...),
+			want:    []Star{star(1, ""), star(1, "")},
+		},
+		"removals beyond the occurrences": {
+			records: append(added(star(1, "")), removed(star(1, ""), star(1, ""))...),
+		},
+		"same time in another location": {
+			records: append(added(star(1, "a")), removed(Star{StarredAt: star(1, "a").StarredAt.In(time.FixedZone("x", 3600)), Login: "a"})...),
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			h := newHistory(tt.records...)
+			if len(h.stars) != len(tt.want) {
+				t.Fatalf("expected %d stars, got %d: %v", len(tt.want), len(h.stars), h.stars)
+			}
+			for i := range tt.want {
+				if !h.stars[i].StarredAt.Equal(tt.want[i].StarredAt) || h.stars[i].Login != tt.want[i].Login {
+					t.Errorf("star %d: expected %v, got %v", i, tt.want[i], h.stars[i])
+				}
+			}
+		})
+	}
+}
+
+func TestHistoryDiff(t *testing.T) {
+	for name, tt := range map[string]struct {
+		stored      []starRecord
+		stars       []Star
+		wantAdded   []starRecord
+		wantRemoved []starRecord
+	}{
+		"nothing stored": {
+			stars:     []Star{star(1, "a"), star(2, "b")},
+			wantAdded: added(star(1, "a"), star(2, "b")),
+		},
+		"all stored": {
+			stored: added(star(1, "a"), star(2, "b")),
+			stars:  []Star{star(1, "a"), star(2, "b")},
+		},
+		"new star": {
+			stored:    added(star(1, "a")),
+			stars:     []Star{star(1, "a"), star(2, "b")},
+			wantAdded: added(star(2, "b")),
+		},
+		"star gone": {
+			stored:      added(star(1, "a"), star(2, "b")),
+			stars:       []Star{star(2, "b")},
+			wantRemoved: removed(star(1, "a")),
+		},
+		"star replaced": {
+			stored:      added(star(1, "a")),
+			stars:       []Star{star(2, "b")},
+			wantAdded:   added(star(2, "b")),
+			wantRemoved: removed(star(1, "a")),
+		},
+		"more identical stars": {
+			stored:    added(star(1, "")),
+			stars:     []Star{star(1, ""), star(1, ""), star(1, "")},
+			wantAdded: added(star(1, ""), star(1, "")),
+		},
+		"fewer identical stars": {
+			stored:      added(star(1, ""), star(1, ""), star(1, "")),
+			stars:       []Star{star(1, "")},
+			wantRemoved: removed(star(1, ""), star(1, "")),
+		},
+		"removed star listed again": {
+			stored:    append(added(star(1, "a")), removed(star(1, "a"))...),
+			stars:     []Star{star(1, "a")},
+			wantAdded: added(star(1, "a")),
+		},
+		"nothing listed": {
+			stored:      added(star(1, "a")),
+			wantRemoved: removed(star(1, "a")),
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			gotAdded, gotRemoved := newHistory(tt.stored...).diff(tt.stars)
+			if !reflect.DeepEqual(gotAdded, tt.wantAdded) {
+				t.Errorf("expected added %v, got %v", tt.wantAdded, gotAdded)
+			}
+			if !reflect.DeepEqual(gotRemoved, tt.wantRemoved) {
+				t.Errorf("expected removed %v, got %v", tt.wantRemoved, gotRemoved)
+			}
+		})
+	}
+}
+
+func TestStoreSyncStars(t *testing.T) {
+	path := t.TempDir()
+	store, err := New(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.AddStars("Owner/Repo", []Star{star(1, "a"), star(2, "b"), star(3, "c")}); err != nil {
+		t.Fatal(err)
+	}
+	added, removed, err := store.SyncStars("owner/repo", []Star{star(1, "a"), star(3, "c"), star(4, "d")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added != 1 || removed != 1 {
+		t.Fatalf("expected 1 added and 1 removed, got %d and %d", added, removed)
+	}
+
+	// a new store reads the same history back from disk.
+	reopened, err := New(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stars, err := reopened.Stars("owner/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var logins []string
+	for _, star := range stars {
+		logins = append(logins, star.Login)
+	}
+	if want := []string{"a", "c", "d"}; !reflect.DeepEqual(logins, want) {
+		t.Fatalf("expected %v, got %v", want, logins)
+	}
+}
+
+func TestStoreInvalidRepo(t *testing.T) {
+	store, err := New(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"", "owner", "owner/repo/extra", "../repo", "owner/..", "owner/re po"} {
+		if _, err := store.Stars(name); err != ErrInvalidRepo {
+			t.Errorf("%q: expected ErrInvalidRepo, got %v", name, err)
+		}
+	}
+}
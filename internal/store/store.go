@@ -0,0 +1,190 @@
+// Package store keeps the star history of repositories on disk, in
+// append-only files, so it outlives cache evictions and GitHub outages.
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"container/list"
+	"encoding/json"
+	"errors"
+	"github.com/apex/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ErrInvalidRepo is returned for names that are not owner/repo.
+var ErrInvalidRepo = errors.New("store: invalid repository name")
+
+var repoName = regexp.MustCompile(`^[a-z0-9_.-]+/[a-z0-9_.-]+$`)
+
+const (
+	starsFile  = "stars.jsonl"
+	countsFile = "counts.jsonl"
+)
+
+var appendedRecords = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "starcharts",
+	Subsystem: "store",
+	Name:      "appended_records_total",
+	Help:      "records appended to the store, by kind",
+}, []string{"kind"})
+
+var evictedHistories = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "starcharts",
+	Subsystem: "store",
+	Name:      "evicted_histories_total",
+	Help:      "histories dropped from memory to keep within the limit",
+})
+
+func init() {
+	prometheus.MustRegister(appendedRecords, evictedHistories)
+}
+
+// Store keeps the history of each repository in a directory of its own under
+// its path: the stars ever seen and the star counts recorded over time, one
+// JSON record per line. Records are only ever appended. The counts of a
+// repository are loaded in memory the first time its history is used, its
+// stars, much bigger, the first time they are. At most maxRepos histories are
+// kept in memory, the least recently used ones are read again when needed.
+type Store struct {
+	path     string
+	maxRepos int
+
+	lock  sync.Mutex
+	repos map[string]*list.Element
+	lru   *list.List
+}
+
+// New opens the store at path, creating it if needed. It keeps up to
+// maxRepos histories in memory, zero meaning no limit.
+func New(path string, maxRepos int) (*Store, error) {
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return nil, err
+	}
+	return &Store{path: path, maxRepos: maxRepos, repos: map[string]*list.Element{}, lru: list.New()}, nil
+}
+
+// history loads the counts of the repository, unless its history is in
+// memory already. release must be called once done with the history, which
+// can't be evicted until then: a second history of the same repository would
+// miss what the first one appends.
+func (s *Store) history(name string) (h *history, release func(), err error) {
+	name = strings.ToLower(name)
+	owner, repo, _ := strings.Cut(name, "/")
+	if !repoName.MatchString(name) || isDots(owner) || isDots(repo) {
+		return nil, nil, ErrInvalidRepo
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if element, ok := s.repos[name]; ok {
+		s.lru.MoveToFront(element)
+		h = element.Value.(*history)
+	} else {
+		h = &history{name: name, dir: filepath.Join(s.path, owner, repo), seen: map[starKey]int{}}
+		if h.countsPartial, err = readRecords(filepath.Join(h.dir, countsFile), func(count Count) {
+			h.counts = append(h.counts, count)
+		}); err != nil {
+			return nil, nil, err
+		}
+		s.repos[name] = s.lru.PushFront(h)
+	}
+	h.users++
+	s.evict()
+	return h, func() {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		h.users--
+		s.evict()
+	}, nil
+}
+
+// evict drops the least recently used histories nobody uses until the limit
+// is met. Must be called with the lock held.
+func (s *Store) evict() {
+	for element := s.lru.Back(); element != nil && s.maxRepos > 0 && s.lru.Len() > s.maxRepos; {
+		previous := element.Prev()
+		if h := element.Value.(*history); h.users == 0 {
+			s.lru.Remove(element)
+			delete(s.repos, h.name)
+			evictedHistories.Inc()
+		}
+		element = previous
+	}
+}
+
+func isDots(s string) bool {
+	return s == "." || s == ".."
+}
+
+// readRecords decodes every line of the file, skipping the ones that can't be
+// decoded. It also reports whether the last line was cut short, as happens
+// when the process dies mid-write, so the next append starts on a new line.
+func readRecords[T any](path string, fn func(record T)) (partial bool, err error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			var record T
+			if err := json.Unmarshal(line, &record); err != nil {
+				log.WithError(err).WithField("file", path).Warn("skipping invalid record")
+			} else {
+				fn(record)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return len(line) > 0, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+}
+
+// appendRecords writes the records at the end of the file and syncs it,
+// creating the file and its directory if needed.
+func appendRecords[T any](path string, partial bool, kind string, records []T) error {
+	var buf bytes.Buffer
+	if partial {
+		buf.WriteByte('\n')
+	}
+	encoder := json.NewEncoder(&buf)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	appendedRecords.WithLabelValues(kind).Add(float64(len(records)))
+	return f.Close()
+}
@@ -0,0 +1,52 @@
+package store
+
+import (
+	"testing"
+)
+
+func TestStoreEviction(t *testing.T) {
+	store, err := New(t.TempDir(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.AddStars("owner/a", []Star{star(1, "a")}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a history in use stays, however many histories are loaded meanwhile.
+	h, release, err := store.history("owner/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"owner/b", "owner/c", "owner/d"} {
+		if _, err := store.Stars(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if again, againRelease, err := store.history("owner/a"); err != nil || again != h {
+		t.Fatalf("expected the history in use, got %p, %v", again, err)
+	} else {
+		againRelease()
+	}
+	release()
+	if n := store.lru.Len(); n != 2 {
+		t.Fatalf("expected 2 histories once released, got %d", n)
+	}
+
+	// evicted histories are read from disk again.
+	for _, name := range []string{"owner/e", "owner/f"} {
+		if _, err := store.Stars(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := store.repos["owner/a"]; ok {
+		t.Fatal("expected owner/a to be evicted")
+	}
+	stars, err := store.Stars("owner/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stars) != 1 || stars[0].Login != "a" {
+		t.Fatalf("expected the stored star, got %v", stars)
+	}
+}
@@ -0,0 +1,136 @@
+package timeline
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Bucket is a calendar period star events are grouped by.
+type Bucket string
+
+const (
+	Day   Bucket = "day"
+	Week  Bucket = "week"
+	Month Bucket = "month"
+)
+
+// ParseBucket validates a bucket name, empty defaults to def.
+func ParseBucket(name string, def Bucket) (Bucket, error) {
+	switch bucket := Bucket(name); bucket {
+	case "":
+		return def, nil
+	case Day, Week, Month:
+		return bucket, nil
+	default:
+		return "", fmt.Errorf("invalid bucket: %s", name)
+	}
+}
+
+// Truncate returns the start of the bucket t falls in, in UTC. Weeks start on
+// Monday.
+func (b Bucket) Truncate(t time.Time) time.Time {
+	year, month, day := t.UTC().Date()
+	switch b {
+	case Week:
+		start := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+		return start.AddDate(0, 0, -(int(start.Weekday())+6)%7)
+	case Month:
+		return time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// Next returns the start of the bucket following the one t falls in.
+func (b Bucket) Next(t time.Time) time.Time {
+	start := b.Truncate(t)
+	switch b {
+	case Week:
+		return start.AddDate(0, 0, 7)
+	case Month:
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// Point is the count of events in the bucket starting at Time.
+type Point struct {
+	Time  time.Time
+	Count int
+}
+
+// Count groups the sorted times into buckets, including empty buckets between
+// the first and the last one.
+func Count(times []time.Time, b Bucket) []Point {
+	if len(times) == 0 {
+		return nil
+	}
+
+	points := []Point{{Time: b.Truncate(times[0])}}
+	for _, t := range times {
+		for !t.Before(b.Next(points[len(points)-1].Time)) {
+			points = append(points, Point{Time: b.Next(points[len(points)-1].Time)})
+		}
+		points[len(points)-1].Count++
+	}
+	return points
+}
+
+// Cumulative groups the sorted times into buckets like Count, but each point
+// holds the running total up to the end of its bucket.
+func Cumulative(times []time.Time, b Bucket) []Point {
+	points := Count(times, b)
+	total := 0
+	for i := range points {
+		total += points[i].Count
+		points[i].Count = total
+	}
+	return points
+}
+
+// Spread turns sorted cumulative points, only known at a few times, into
+// counts per bucket, interpolating linearly between the points.
+func Spread(points []Point, b Bucket) []Point {
+	if len(points) == 0 {
+		return nil
+	}
+
+	first, last := points[0].Time, points[len(points)-1].Time
+	var spread []Point
+	previous := points[0].Count
+	for start := b.Truncate(first); !start.After(last); start = b.Next(start) {
+		end := b.Next(start)
+		if end.After(last) {
+			end = last
+		}
+		total := int(math.Round(interpolate(points, end)))
+		spread = append(spread, Point{Time: start, Count: total - previous})
+		previous = total
+	}
+	return spread
+}
+
+// interpolate returns the cumulative count at t, linearly interpolated
+// between the points around it.
+func interpolate(points []Point, t time.Time) float64 {
+	i := sort.Search(len(points), func(i int) bool {
+		return !points[i].Time.Before(t)
+	})
+	switch {
+	case i == 0:
+		return float64(points[0].Count)
+	case i == len(points):
+		return float64(points[len(points)-1].Count)
+	}
+
+	before, after := points[i-1], points[i]
+	span := after.Time.Sub(before.Time)
+	if span <= 0 {
+		return float64(after.Count)
+	}
+	ratio := float64(t.Sub(before.Time)) / float64(span)
+	return float64(before.Count) + ratio*float64(after.Count-before.Count)
+}
@@ -0,0 +1,195 @@
+package timeline
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day, hour int) time.Time {
+	return time.Date(year, month, day, hour, 0, 0, 0, time.UTC)
+}
+
+func TestParseBucket(t *testing.T) {
+	for name, tt := range map[string]struct {
+		want    Bucket
+		invalid bool
+	}{
+		"":      {want: Week},
+		"day":   {want: Day},
+		"week":  {want: Week},
+		"month": {want: Month},
+		"year":  {invalid: true},
+		"Day":   {invalid: true},
+	} {
+		got, err := ParseBucket(name, Week)
+		if tt.invalid != (err != nil) || got != tt.want {
+			t.Errorf("%q: expected %q, got %q, %v", name, tt.want, got, err)
+		}
+	}
+}
+
+func TestBucketTruncate(t *testing.T) {
+	paris := time.FixedZone("paris", 2*3600)
+	for name, tt := range map[string]struct {
+		bucket Bucket
+		t      time.Time
+		want   time.Time
+		next   time.Time
+	}{
+		"day": {
+			bucket: Day,
+			t:      date(2024, 3, 5, 15),
+			want:   date(2024, 3, 5, 0),
+			next:   date(2024, 3, 6, 0),
+		},
+		"day in utc": {
+			bucket: Day,
+			t:      time.Date(2024, 3, 5, 1, 0, 0, 0, paris),
+			want:   date(2024, 3, 4, 0),
+			next:   date(2024, 3, 5, 0),
+		},
+		"week from wednesday": {
+			bucket: Week,
+			t:      date(2024, 3, 6, 12),
+			want:   date(2024, 3, 4, 0),
+			next:   date(2024, 3, 11, 0),
+		},
+		"week from monday": {
+			bucket: Week,
+			t:      date(2024, 3, 4, 0),
+			want:   date(2024, 3, 4, 0),
+			next:   date(2024, 3, 11, 0),
+		},
+		"week from sunday": {
+			bucket: Week,
+			t:      date(2024, 3, 10, 23),
+			want:   date(2024, 3, 4, 0),
+			next:   date(2024, 3, 11, 0),
+		},
+		"week across months": {
+			bucket: Week,
+			t:      date(2024, 3, 1, 0),
+			want:   date(2024, 2, 26, 0),
+			next:   date(2024, 3, 4, 0),
+		},
+		"month": {
+			bucket: Month,
+			t:      date(2024, 2, 29, 8),
+			want:   date(2024, 2, 1, 0),
+			next:   date(2024, 3, 1, 0),
+		},
+		"month across years": {
+			bucket: Month,
+			t:      date(2024, 12, 31, 8),
+			want:   date(2024, 12, 1, 0),
+			next:   date(2025, 1, 1, 0),
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got := tt.bucket.Truncate(tt.t); !got.Equal(tt.want) {
+				t.Errorf("expected truncated to %s, got %s", tt.want, got)
+			}
+			if got := tt.bucket.Next(tt.t); !got.Equal(tt.next) {
+				t.Errorf("expected next %s, got %s", tt.next, got)
+			}
+		})
+	}
+}
+
+func TestCount(t *testing.T) {
+	for name, tt := range map[string]struct {
+		times      []time.Time
+		bucket     Bucket
+		want       []Point
+		cumulative []Point
+	}{
+		"no times": {
+			bucket: Day,
+		},
+		"one bucket": {
+			times:      []time.Time{date(2024, 3, 5, 1), date(2024, 3, 5, 2)},
+			bucket:     Day,
+			want:       []Point{{Time: date(2024, 3, 5, 0), Count: 2}},
+			cumulative: []Point{{Time: date(2024, 3, 5, 0), Count: 2}},
+		},
+		"empty buckets in between": {
+			times:  []time.Time{date(2024, 3, 5, 1), date(2024, 3, 8, 2), date(2024, 3, 8, 3)},
+			bucket: Day,
+			want: []Point{
+				{Time: date(2024, 3, 5, 0), Count: 1},
+				{Time: date(2024, 3, 6, 0)},
+				{Time: date(2024, 3, 7, 0)},
+				{Time: date(2024, 3, 8, 0), Count: 2},
+			},
+			cumulative: []Point{
+				{Time: date(2024, 3, 5, 0), Count: 1},
+				{Time: date(2024, 3, 6, 0), Count: 1},
+				{Time: date(2024, 3, 7, 0), Count: 1},
+				{Time: date(2024, 3, 8, 0), Count: 3},
+			},
+		},
+		"months": {
+			times:  []time.Time{date(2024, 1, 31, 23), date(2024, 2, 1, 0), date(2024, 3, 31, 0)},
+			bucket: Month,
+			want: []Point{
+				{Time: date(2024, 1, 1, 0), Count: 1},
+				{Time: date(2024, 2, 1, 0), Count: 1},
+				{Time: date(2024, 3, 1, 0), Count: 1},
+			},
+			cumulative: []Point{
+				{Time: date(2024, 1, 1, 0), Count: 1},
+				{Time: date(2024, 2, 1, 0), Count: 2},
+				{Time: date(2024, 3, 1, 0), Count: 3},
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got := Count(tt.times, tt.bucket); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected counts %v, got %v", tt.want, got)
+			}
+			if got := Cumulative(tt.times, tt.bucket); !reflect.DeepEqual(got, tt.cumulative) {
+				t.Errorf("expected cumulative counts %v, got %v", tt.cumulative, got)
+			}
+		})
+	}
+}
+
+func TestSpread(t *testing.T) {
+	for name, tt := range map[string]struct {
+		points []Point
+		want   []Point
+	}{
+		"no points": {},
+		"linear": {
+			points: []Point{
+				{Time: date(2024, 3, 1, 0)},
+				{Time: date(2024, 3, 4, 0), Count: 30},
+			},
+			want: []Point{
+				{Time: date(2024, 3, 1, 0), Count: 10},
+				{Time: date(2024, 3, 2, 0), Count: 10},
+				{Time: date(2024, 3, 3, 0), Count: 10},
+				{Time: date(2024, 3, 4, 0), Count: 0},
+			},
+		},
+		"between points": {
+			points: []Point{
+				{Time: date(2024, 3, 1, 0), Count: 100},
+				{Time: date(2024, 3, 2, 0), Count: 110},
+				{Time: date(2024, 3, 3, 12), Count: 140},
+			},
+			want: []Point{
+				{Time: date(2024, 3, 1, 0), Count: 10},
+				{Time: date(2024, 3, 2, 0), Count: 20},
+				{Time: date(2024, 3, 3, 0), Count: 10},
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got := Spread(tt.points, Day); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
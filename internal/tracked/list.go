@@ -0,0 +1,147 @@
+// Package tracked keeps the list of repositories whose charts are kept warm
+// and refreshes them periodically.
+package tracked
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ErrInvalidRepo is returned for names that are not owner/repo.
+var ErrInvalidRepo = errors.New("tracked: invalid repository name")
+
+var repoExpression = regexp.MustCompile(`^[\w.-]+/[\w.-]+$`)
+
+// List is the set of tracked repositories, saved in a file with one
+// owner/repo per line. Blank lines and lines starting with # are ignored.
+type List struct {
+	path string
+
+	lock    sync.RWMutex
+	repos   []string
+	changed chan struct{}
+}
+
+// Load reads the list from the file at path, a missing file is an empty list.
+func Load(path string) (*List, error) {
+	list := &List{path: path, changed: make(chan struct{}, 1)}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return list, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		name := strings.TrimSpace(scanner.Text())
+		if name == "" || strings.HasPrefix(name, "#") {
+			continue
+		}
+		if !validName(name) {
+			return nil, fmt.Errorf("%s: %w: %s", path, ErrInvalidRepo, name)
+		}
+		if !list.contains(name) {
+			list.repos = append(list.repos, name)
+		}
+	}
+	return list, scanner.Err()
+}
+
+// Repos returns the tracked repositories.
+func (l *List) Repos() []string {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	return slices.Clone(l.repos)
+}
+
+// Changed is notified when repositories are added.
+func (l *List) Changed() <-chan struct{} {
+	return l.changed
+}
+
+// Add tracks the repository and saves the list, reporting whether it wasn't
+// tracked yet.
+func (l *List) Add(name string) (bool, error) {
+	if !validName(name) {
+		return false, ErrInvalidRepo
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.contains(name) {
+		return false, nil
+	}
+
+	repos := append(slices.Clone(l.repos), name)
+	if err := l.save(repos); err != nil {
+		return false, err
+	}
+	l.repos = repos
+	select {
+	case l.changed <- struct{}{}:
+	default:
+	}
+	return true, nil
+}
+
+// Remove stops tracking the repository and saves the list, reporting whether
+// it was tracked.
+func (l *List) Remove(name string) (bool, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	repos := slices.DeleteFunc(slices.Clone(l.repos), func(repo string) bool {
+		return strings.EqualFold(repo, name)
+	})
+	if len(repos) == len(l.repos) {
+		return false, nil
+	}
+
+	if err := l.save(repos); err != nil {
+		return false, err
+	}
+	l.repos = repos
+	return true, nil
+}
+
+// validName reports whether the name is owner/repo, neither being a dot path.
+func validName(name string) bool {
+	owner, repo, _ := strings.Cut(name, "/")
+	return repoExpression.MatchString(name) && strings.Trim(owner, ".") != "" && strings.Trim(repo, ".") != ""
+}
+
+// contains reports whether the repository is tracked, names are case
+// insensitive. Must be called with the lock held.
+func (l *List) contains(name string) bool {
+	return slices.ContainsFunc(l.repos, func(repo string) bool {
+		return strings.EqualFold(repo, name)
+	})
+}
+
+// save replaces the file with the repositories, through a temporary file so
+// it is never left half written.
+func (l *List) save(repos []string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(l.path), filepath.Base(l.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	content := "# tracked repositories, one owner/repo per line\n" + strings.Join(repos, "\n") + "\n"
+	if _, err := tmp.WriteString(content); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), l.path)
+}
@@ -0,0 +1,95 @@
+package tracked
+
+import (
+	"context"
+	"github.com/apex/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"strarcharts/internal/github"
+	"time"
+)
+
+var refreshes = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "starcharts",
+	Subsystem: "tracked",
+	Name:      "refreshes_total",
+	Help:      "refreshes of tracked repositories, by result",
+}, []string{"result"})
+
+func init() {
+	prometheus.MustRegister(refreshes)
+}
+
+// Refresher refreshes a repository.
+type Refresher func(ctx context.Context, name string) error
+
+// Scheduler refreshes every tracked repository every interval, one at a time
+// and as background GitHub work, so live requests go first. Repositories are
+// also refreshed as soon as they are added.
+type Scheduler struct {
+	list     *List
+	refresh  Refresher
+	interval time.Duration
+
+	refreshed map[string]bool
+}
+
+// NewScheduler creates a scheduler refreshing the repositories of the list.
+func NewScheduler(list *List, refresh Refresher, interval time.Duration) *Scheduler {
+	return &Scheduler{list: list, refresh: refresh, interval: interval, refreshed: map[string]bool{}}
+}
+
+// Run refreshes the repositories until ctx is done, a non-positive interval
+// disables refreshes.
+func (s *Scheduler) Run(ctx context.Context) {
+	if s.interval <= 0 {
+		log.Info("tracked repositories refresh disabled")
+		return
+	}
+
+	ctx = github.Background(ctx)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	all := true
+	for {
+		s.refreshAll(ctx, all)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			all = true
+		case <-s.list.Changed():
+			all = false
+		}
+	}
+}
+
+// refreshAll refreshes the tracked repositories, or only the ones that were
+// never refreshed unless all is set.
+func (s *Scheduler) refreshAll(ctx context.Context, all bool) {
+	names := s.list.Repos()
+	refreshed := map[string]bool{}
+	for _, name := range names {
+		refreshed[name] = s.refreshed[name]
+	}
+	s.refreshed = refreshed
+
+	for _, name := range names {
+		if ctx.Err() != nil {
+			return
+		}
+		if !all && s.refreshed[name] {
+			continue
+		}
+		s.refreshed[name] = true
+
+		log := log.WithField("repo", name)
+		start := time.Now()
+		if err := s.refresh(ctx, name); err != nil {
+			log.WithError(err).Warn("failed to refresh tracked repository")
+			refreshes.WithLabelValues("failed").Inc()
+			continue
+		}
+		log.WithField("duration", time.Since(start)).Info("refreshed tracked repository")
+		refreshes.WithLabelValues("success").Inc()
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"embed"
 	"github.com/apex/httplog"
 	"github.com/apex/log"
@@ -16,6 +17,10 @@ import (
 	"strarcharts/controller"
 	"strarcharts/internal/cache"
 	github2 "strarcharts/internal/github"
+	"strarcharts/internal/jobs"
+	"strarcharts/internal/snapshots"
+	store2 "strarcharts/internal/store"
+	"strarcharts/internal/tracked"
 	"time"
 )
 
@@ -30,14 +35,21 @@ func main() {
 	log.SetHandler(text.New(os.Stderr))
 	config := config2.Get()
 	ctx := log.WithField("listen", config.Listen)
-	options, err := redis.ParseURL(config.RedisUrl)
-	if err != nil {
-		log.WithError(err).Fatal("invalid redis_url")
-	}
-	redis := redis.NewClient(options)
-	cache := cache.New(redis)
+	cache := newCache(config)
 	defer cache.Close()
 	github := github2.New(config, cache)
+	store, err := store2.New(config.StorePath, config.StoreMaxRepos)
+	if err != nil {
+		log.WithError(err).Fatal("failed to open store")
+	}
+	trackedRepos, err := tracked.Load(config.TrackedReposFile)
+	if err != nil {
+		log.WithError(err).Fatal("failed to load tracked repositories")
+	}
+	go snapshots.New(github, store, trackedRepos, config.SnapshotInterval).Run(context.Background())
+	refresh := controller.WarmRepoCharts(github, cache, store, config.CacheChartTTL, config.CacheChartFreshness)
+	go tracked.NewScheduler(trackedRepos, refresh, config.TrackedRefreshInterval).Run(context.Background())
+	queue := jobs.New(config.JobsWorkers, config.JobsQueueSize, config.JobsTimeout, config.JobsStatusTTL)
 
 	r := mux.NewRouter()
 	r.Path("/").
@@ -49,9 +61,36 @@ func main() {
 	r.PathPrefix("/static/").
 		Methods(http.MethodGet).
 		Handler(http.FileServer(http.FS(static)))
+	r.Path("/admin/tracked").
+		Methods(http.MethodGet).
+		Handler(controller.GetTracked(trackedRepos, config.AdminToken))
+	r.Path("/admin/tracked").
+		Methods(http.MethodPost).
+		Handler(controller.AddTracked(trackedRepos, config.AdminToken))
+	r.Path("/admin/tracked/{owner}/{repo}").
+		Methods(http.MethodDelete).
+		Handler(controller.RemoveTracked(trackedRepos, config.AdminToken))
+	r.Path("/compare.svg").
+		Methods(http.MethodGet).
+		Handler(controller.GetCompareChart(github, cache, store, config.CacheChartTTL, config.CacheChartFreshness))
 	r.Path("/{owner}/{repo}.svg").
 		Methods(http.MethodGet).
-		Handler(controller.GetRepoChart(github, cache))
+		Handler(controller.GetRepoChart(github, cache, store, queue, config.CacheChartTTL, config.CacheChartFreshness, config.ChartAsyncMinPages))
+	r.Path("/{owner}/{repo}.png").
+		Methods(http.MethodGet).
+		Handler(controller.GetRepoChartPNG(github, cache, store, queue, config.CacheChartTTL, config.CacheChartFreshness, config.ChartAsyncMinPages))
+	r.Path("/{owner}/{repo}.json").
+		Methods(http.MethodGet).
+		Handler(controller.GetRepoJSON(github, store))
+	r.Path("/{owner}/{repo}.csv").
+		Methods(http.MethodGet).
+		Handler(controller.GetRepoCSV(github, store))
+	r.Path("/{owner}/{repo}/status").
+		Methods(http.MethodGet).
+		Handler(controller.GetRepoStatus(github, queue))
+	r.Path("/{owner}/{repo}/events").
+		Methods(http.MethodGet).
+		Handler(controller.GetRepoEvents(github, queue))
 	r.Path("/{owner}/{repo}").
 		Methods(http.MethodGet).
 		Handler(controller.GetRepo(static, github, cache, version))
@@ -72,7 +111,7 @@ func main() {
 	r.Methods(http.MethodGet).Path("/metrics").Handler(promhttp.Handler())
 
 	srv := &http.Server{
-		Handler: httplog.New(
+		Handler: controller.KeepResponseWriter(httplog.New(
 			promhttp.InstrumentHandlerDuration(
 				responseObserver,
 				promhttp.InstrumentHandlerCounter(
@@ -80,7 +119,7 @@ func main() {
 					r,
 				),
 			),
-		),
+		)),
 		Addr:         config.Listen,
 		WriteTimeout: 60 * time.Second,
 		ReadTimeout:  60 * time.Second,
@@ -88,3 +127,20 @@ func main() {
 	ctx.Info("starting up...")
 	ctx.WithError(srv.ListenAndServe()).Error("failed to start up server")
 }
+
+// newCache creates the cache backend selected by CACHE_BACKEND.
+func newCache(config config2.Config) cache.Cache {
+	switch config.CacheBackend {
+	case "memory":
+		return cache.NewMemory(config.CacheMemoryMaxItems, config.CacheMemoryMaxBytes, config.CacheMemoryTTL)
+	case "redis":
+		options, err := redis.ParseURL(config.RedisUrl)
+		if err != nil {
+			log.WithError(err).Fatal("invalid redis_url")
+		}
+		return cache.NewRedis(redis.NewClient(options))
+	default:
+		log.Fatalf("invalid cache_backend: %s", config.CacheBackend)
+		return nil
+	}
+}
@@ -107,5 +107,61 @@
         });
     });
 
+    const progressElement = document.querySelector('#chart-progress');
+    const progressBarElement = progressElement.querySelector('progress');
+    const progressLabelElement = progressElement.querySelector('.chart-progress-label');
+    const events = new EventSource(progressElement.dataset.events);
+    let reloaded = false;
+
+    function reloadChart() {
+        const url = new URL(chartElement.src);
+        url.searchParams.set('t', Date.now().toString());
+        chartElement.src = url.toString();
+    }
+
+    events.addEventListener('status', function (e) {
+        const status = JSON.parse(e.data);
+        if (status.total > 0) {
+            progressElement.hidden = false;
+            progressBarElement.value = status.fetched / status.total;
+            progressLabelElement.innerText = 'Fetching stargazers: ' + status.fetched + ' of ' + status.total + ' pages';
+        }
+    });
+
+    events.addEventListener('done', function () {
+        events.close();
+        progressElement.hidden = true;
+        reloaded = true;
+        reloadChart();
+    });
+
+    events.addEventListener('failed', function (e) {
+        events.close();
+        progressElement.hidden = false;
+        progressElement.classList.add('failed');
+        progressLabelElement.innerText = 'Failed to fetch stargazers: ' + JSON.parse(e.data).error;
+    });
+
+    // the chart is either the real one or a placeholder while it is built in
+    // the background, which the status tells apart.
+    chartElement.addEventListener('load', function () {
+        if (events.readyState === EventSource.CLOSED) return;
+        fetch(progressElement.dataset.status)
+            .then(function (response) {
+                return response.json();
+            })
+            .then(function (status) {
+                if (status.state === 'queued' || status.state === 'running') return;
+                if (status.state === 'done' && !reloaded) {
+                    // the build might have finished before the stream saw it.
+                    reloaded = true;
+                    reloadChart();
+                    return;
+                }
+                events.close();
+                progressElement.hidden = true;
+            });
+    });
+
     refreshState('adaptive');
 })();